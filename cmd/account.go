@@ -28,55 +28,67 @@ var accountCmd = &cobra.Command{
 				ui.Error("Session expired. Run `codag login` to re-authenticate.")
 				return silent(err)
 			}
-			return err
+			return handleAPIError(err, server)
 		}
 
-		fmt.Println()
-		ui.Keyval("User", me.User.GithubLogin)
-
-		// Show work email if available, otherwise GitHub email
-		if me.User.WorkEmail != "" {
-			ui.Keyval("Email", me.User.WorkEmail)
-		} else if me.User.Email != "" {
-			ui.Keyval("Email", me.User.Email)
+		result := accountResult{
+			User:  me.User.GithubLogin,
+			Email: me.User.WorkEmail, // work email if available, otherwise GitHub email
+			Plan:  "free",
+			Orgs:  []string{},
 		}
-
-		// Plan
-		tier := "Free"
-		if me.Subscription != nil && me.Subscription.Tier != "" {
-			tier = strings.ToUpper(me.Subscription.Tier[:1]) + me.Subscription.Tier[1:]
+		if result.Email == "" {
+			result.Email = me.User.Email
 		}
-		ui.Keyval("Plan", tier)
-
-		if me.Subscription != nil && me.Subscription.CancelAtPeriodEnd {
-			ui.Warn("Cancellation pending — reverts to Free at period end")
+		if me.Subscription != nil {
+			if me.Subscription.Tier != "" {
+				result.Plan = strings.ToLower(me.Subscription.Tier)
+			}
+			result.CancelAtPeriodEnd = me.Subscription.CancelAtPeriodEnd
 		}
-
 		// Repos - count personal + org repos
-		repoCount := len(me.Repos)
+		result.Repos = len(me.Repos)
 		for _, org := range me.Orgs {
-			repoCount += org.RepoCount
+			result.Repos += org.RepoCount
+			result.Orgs = append(result.Orgs, org.Name)
 		}
-		ui.Keyval("Repos", fmt.Sprintf("%d", repoCount))
 
-		// Orgs - use singular when only one
-		if len(me.Orgs) > 0 {
-			names := make([]string, len(me.Orgs))
-			for i, o := range me.Orgs {
-				names[i] = o.Name
-			}
-			label := "Orgs"
-			if len(me.Orgs) == 1 {
-				label = "Org"
-			}
-			ui.Keyval(label, strings.Join(names, ", "))
-		}
-
-		fmt.Println()
-		return nil
+		return render(cmd, result, func() { printAccount(result) })
 	},
 }
 
+type accountResult struct {
+	User              string   `json:"user" yaml:"user"`
+	Email             string   `json:"email" yaml:"email"`
+	Plan              string   `json:"plan" yaml:"plan"`
+	CancelAtPeriodEnd bool     `json:"cancel_at_period_end" yaml:"cancel_at_period_end"`
+	Repos             int      `json:"repos" yaml:"repos"`
+	Orgs              []string `json:"orgs" yaml:"orgs"`
+}
+
+func printAccount(result accountResult) {
+	fmt.Fprintln(textOut)
+	ui.Keyval("User", result.User)
+	if result.Email != "" {
+		ui.Keyval("Email", result.Email)
+	}
+	ui.Keyval("Plan", strings.ToUpper(result.Plan[:1])+result.Plan[1:])
+	if result.CancelAtPeriodEnd {
+		ui.Warn("Cancellation pending — reverts to Free at period end")
+	}
+	ui.Keyval("Repos", fmt.Sprintf("%d", result.Repos))
+
+	// Orgs - use singular when only one
+	if len(result.Orgs) > 0 {
+		label := "Orgs"
+		if len(result.Orgs) == 1 {
+			label = "Org"
+		}
+		ui.Keyval(label, strings.Join(result.Orgs, ", "))
+	}
+	fmt.Fprintln(textOut)
+}
+
 func init() {
 	addServerFlag(accountCmd)
 }
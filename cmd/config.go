@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/codag-megalith/codag-cli/internal/config"
+	"github.com/codag-megalith/codag-cli/internal/project"
+	"github.com/codag-megalith/codag-cli/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Get and set CLI settings",
+	Long: `Get and set settings stored in the active profile's env file
+(~/.config/codag/.env on Linux, ~/.codag/.env elsewhere).
+
+Values are taken from flags first, then environment variables, then the
+env file, then the repo's .codag.yml. Use 'codag config list --show-origin' to see which one won.`,
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List settings and their effective values",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		showOrigin, _ := cmd.Flags().GetBool("show-origin")
+
+		type row struct {
+			name, value, origin string
+			unset               bool
+		}
+		var rows []row
+		result := configListResult{Settings: []settingResult{}}
+		nameWidth, valueWidth := 0, 0
+		for _, s := range config.Settings {
+			v := effectiveSetting(cmd, s)
+			result.Settings = append(result.Settings, newSettingResult(s, v))
+			r := row{name: s.Name, value: displayValue(s, v), origin: describeOrigin(v)}
+			if v.Value == "" {
+				r = row{name: s.Name, value: "(not set)", unset: true}
+			}
+			nameWidth = max(nameWidth, len(r.name))
+			valueWidth = max(valueWidth, len([]rune(r.value)))
+			rows = append(rows, r)
+		}
+
+		return render(cmd, result, func() {
+			fmt.Fprintln(textOut)
+			for _, r := range rows {
+				value := r.value
+				if r.unset {
+					value = ui.Dim.Render(value)
+				}
+				if !showOrigin {
+					fmt.Fprintf(textOut, "  %-*s  %s\n", nameWidth, r.name, value)
+					continue
+				}
+				pad := strings.Repeat(" ", valueWidth-len([]rune(r.value)))
+				fmt.Fprintf(textOut, "  %-*s  %s%s  %s\n", nameWidth, r.name, value, pad, ui.Dim.Render(r.origin))
+			}
+			fmt.Fprintln(textOut)
+		})
+	},
+}
+
+type configListResult struct {
+	Settings []settingResult `json:"settings" yaml:"settings"`
+}
+
+// settingResult is a setting's effective value. Secrets are masked.
+type settingResult struct {
+	Name   string `json:"name" yaml:"name"`
+	Value  string `json:"value" yaml:"value"`
+	Source string `json:"source" yaml:"source"`
+	Origin string `json:"origin" yaml:"origin"`
+}
+
+func newSettingResult(s config.Setting, v config.Value) settingResult {
+	return settingResult{Name: s.Name, Value: displayValue(s, v), Source: string(v.Source), Origin: v.Origin}
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print a setting's effective value",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, err := lookupSetting(args[0])
+		if err != nil {
+			return err
+		}
+		v := effectiveSetting(cmd, s)
+		if v.Value == "" {
+			return silent(fmt.Errorf("%s is not set", s.Name))
+		}
+		return render(cmd, newSettingResult(s, v), func() {
+			fmt.Fprintln(textOut, displayValue(s, v))
+		})
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Save a setting to the env file",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, err := lookupSetting(args[0])
+		if err != nil {
+			return err
+		}
+		value := args[1]
+		if err := s.Validate(value); err != nil {
+			ui.Error(err.Error())
+			return silent(err)
+		}
+
+		// Check before saving: SaveEnvVar overwrites the process env too.
+		before := config.Effective(s)
+		if err := config.SaveEnvVar(s.Env, value); err != nil {
+			ui.Error(err.Error())
+			return silent(err)
+		}
+		ui.Success(fmt.Sprintf("Set %s in %s", s.Name, config.EnvFile))
+		if before.Source == config.SourceEnv && before.Origin == s.Env && before.Value != value {
+			ui.Warn(fmt.Sprintf("%s is set in your environment and takes precedence over the file.", before.Origin))
+		}
+		return render(cmd, newSettingResult(s, config.Effective(s)), nil)
+	},
+}
+
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset <key>",
+	Short: "Remove a setting from the env file",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, err := lookupSetting(args[0])
+		if err != nil {
+			return err
+		}
+		if s.ManagedBy != "" {
+			err := fmt.Errorf("%s is managed by '%s'", s.Name, s.ManagedBy)
+			ui.Error(err.Error())
+			return silent(err)
+		}
+		if err := config.RemoveEnvVar(s.Env); err != nil {
+			ui.Error(err.Error())
+			return silent(err)
+		}
+		ui.Success(fmt.Sprintf("Unset %s in %s", s.Name, config.EnvFile))
+		return render(cmd, newSettingResult(s, config.Effective(s)), nil)
+	},
+}
+
+func init() {
+	configListCmd.Flags().Bool("show-origin", false, "Show where each value comes from")
+	addServerFlag(configListCmd)
+	addServerFlag(configGetCmd)
+
+	configCmd.AddCommand(configListCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configUnsetCmd)
+}
+
+// lookupSetting resolves a key name, listing the known keys if it's unknown.
+func lookupSetting(name string) (config.Setting, error) {
+	s, ok := config.LookupSetting(name)
+	if !ok {
+		var names []string
+		for _, s := range config.Settings {
+			names = append(names, s.Name)
+		}
+		ui.Error(fmt.Sprintf("Unknown setting %q", name))
+		fmt.Fprintf(os.Stderr, "  Known settings: %s\n", strings.Join(names, ", "))
+		return s, silent(fmt.Errorf("unknown setting %q", name))
+	}
+	return s, nil
+}
+
+// effectiveSetting is config.Effective with the server flags applied.
+func effectiveSetting(cmd *cobra.Command, s config.Setting) config.Value {
+	if s.Env == "CODAG_SERVER_URL" && cmd.Flags().Lookup("server") != nil {
+		if dev, _ := cmd.Flags().GetBool("dev"); dev {
+			return config.Value{Value: resolveServer(cmd), Source: config.SourceFlag, Origin: "--dev"}
+		}
+		if server, _ := cmd.Flags().GetString("server"); server != "" {
+			return config.Value{Value: server, Source: config.SourceFlag, Origin: "--server"}
+		}
+	}
+	v := config.Effective(s)
+	if v.Source == config.SourceDefault && s.Env == "CODAG_SERVER_URL" {
+		if proj, _ := project.Load("."); proj.Server != "" {
+			return config.Value{Value: proj.Server, Source: config.SourceProject, Origin: proj.Path}
+		}
+	}
+	return v
+}
+
+func displayValue(s config.Setting, v config.Value) string {
+	if s.Secret {
+		return config.Mask(v.Value)
+	}
+	return v.Value
+}
+
+func describeOrigin(v config.Value) string {
+	switch v.Source {
+	case config.SourceFlag:
+		return "flag " + v.Origin
+	case config.SourceEnv:
+		return "env " + v.Origin
+	case config.SourceFile:
+		return "file " + v.Origin
+	case config.SourceKeyring:
+		return "keyring"
+	case config.SourceProject:
+		return "project " + v.Origin
+	}
+	return "default"
+}
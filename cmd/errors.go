@@ -6,6 +6,8 @@ import (
 	"os"
 
 	"github.com/codag-megalith/codag-cli/internal/api"
+	"github.com/codag-megalith/codag-cli/internal/config"
+	"github.com/codag-megalith/codag-cli/internal/httpclient"
 	"github.com/codag-megalith/codag-cli/internal/ui"
 )
 
@@ -27,7 +29,10 @@ func silent(err error) error { return &silentErr{err} }
 // handleAPIError formats API errors for display and returns a silent error.
 func handleAPIError(err error, server string) error {
 	var apiErr *api.APIError
-	if errors.As(err, &apiErr) {
+	if errors.Is(err, api.ErrOrgFilterUnsupported) {
+		ui.Error("This server can't list repos by org.")
+		fmt.Fprintln(os.Stderr, "  Use --owner or --filter, or name the repo.")
+	} else if errors.As(err, &apiErr) {
 		switch apiErr.StatusCode {
 		case 401:
 			ui.Error("Invalid or expired token. Run: codag login")
@@ -35,8 +40,41 @@ func handleAPIError(err error, server string) error {
 			ui.Error(fmt.Sprintf("Error %d: %s", apiErr.StatusCode, apiErr.Detail))
 		}
 	} else {
-		ui.Error(fmt.Sprintf("Cannot connect to %s", server))
-		fmt.Fprintln(os.Stderr, "  Check your connection or try again later.")
+		printConnectError(err, server)
 	}
 	return silent(err)
 }
+
+// printConnectError explains a request that failed before producing an
+// API response: bad transport settings, an unsupported client version, or
+// a network error.
+func printConnectError(err error, server string) {
+	var upgradeErr *httpclient.UpgradeRequiredError
+	if errors.As(err, &upgradeErr) {
+		printUpgradeRequired(upgradeErr)
+		return
+	}
+	var cfgErr *httpclient.ConfigError
+	if errors.As(err, &cfgErr) {
+		ui.Error(cfgErr.Error())
+		fmt.Fprintf(os.Stderr, "  Check your proxy and TLS settings in the environment or %s.\n", config.EnvFile)
+		return
+	}
+	ui.Error(fmt.Sprintf("Cannot connect to %s", server))
+	fmt.Fprintln(os.Stderr, "  Check your connection or try again later.")
+	fmt.Fprintln(os.Stderr, "  Re-run with --debug to see the failing request.")
+}
+
+// printUpgradeRequired tells the user the server no longer supports this
+// version of the CLI.
+func printUpgradeRequired(err *httpclient.UpgradeRequiredError) {
+	if err.MinVersion != "" {
+		ui.Error(fmt.Sprintf("This version of codag (%s) is no longer supported. Version %s or newer is required.", err.Current, err.MinVersion))
+	} else {
+		ui.Error(fmt.Sprintf("This version of codag (%s) is no longer supported by the server.", err.Current))
+	}
+	if err.Detail != "" {
+		fmt.Fprintf(os.Stderr, "  %s\n", err.Detail)
+	}
+	fmt.Fprintln(os.Stderr, "  Run: codag upgrade")
+}
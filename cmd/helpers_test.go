@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/codag-megalith/codag-cli/internal/config"
+	"github.com/codag-megalith/codag-cli/internal/fakeapi"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// authEnvKeys are cleared before each test so the developer's own
+// session never leaks in.
+var authEnvKeys = []string{
+	"CODAG_ACCESS_TOKEN",
+	"CODAG_REFRESH_TOKEN",
+	"CODAG_SERVER_URL",
+	"CODAG_URL",
+	"CODAG_DEBUG",
+	"CODAG_HAR",
+	"CODAG_PROFILE",
+	"CODAG_CREDENTIAL_STORE",
+	"CODAG_PROXY",
+	"CODAG_GIT_HOSTS",
+	"CODAG_DEFAULT_REPO",
+	"SSH_CONNECTION",
+	"SSH_CLIENT",
+	"SSH_TTY",
+	"DISPLAY",
+	"WAYLAND_DISPLAY",
+}
+
+// setupTest starts a fake API, points config at a temp CODAG_HOME, runs
+// the test from a temp working directory and shortens poll intervals.
+func setupTest(t *testing.T) *fakeapi.Server {
+	t.Helper()
+
+	home := t.TempDir()
+	oldHome, oldEnvFile := config.CodagHome, config.EnvFile
+	oldCache, oldState := config.CacheDir, config.StateDir
+	config.CodagHome, config.CacheDir, config.StateDir = home, home, home
+	config.EnvFile = filepath.Join(home, ".env")
+	t.Cleanup(func() {
+		config.CodagHome, config.EnvFile = oldHome, oldEnvFile
+		config.CacheDir, config.StateDir = oldCache, oldState
+	})
+	// Keeps the ~/.codag migration away from the real home directory
+	t.Setenv("CODAG_HOME", home)
+
+	for _, k := range authEnvKeys {
+		t.Setenv(k, "")
+		os.Unsetenv(k)
+	}
+
+	t.Chdir(t.TempDir())
+
+	oldPoll, oldGrace := pollInterval, pollGracePeriod
+	oldMin, oldDefault := minDevicePollInterval, defaultDevicePollInterval
+	oldBrowser, oldTerminal := openBrowser, stdoutIsTerminal
+	oldListen, oldBrowserTimeout := listenLoopback, browserLoginTimeout
+	pollInterval, pollGracePeriod = 5*time.Millisecond, 50*time.Millisecond
+	minDevicePollInterval, defaultDevicePollInterval = 0, 5*time.Millisecond
+	openBrowser = func(string) error { return os.ErrNotExist }
+	stdoutIsTerminal = func() bool { return false }
+	browserLoginTimeout = 5 * time.Second
+	t.Cleanup(func() {
+		pollInterval, pollGracePeriod = oldPoll, oldGrace
+		minDevicePollInterval, defaultDevicePollInterval = oldMin, oldDefault
+		openBrowser, stdoutIsTerminal = oldBrowser, oldTerminal
+		listenLoopback, browserLoginTimeout = oldListen, oldBrowserTimeout
+	})
+
+	return fakeapi.New(t)
+}
+
+// loginAs saves the fake server's current tokens as the active session.
+func loginAs(t *testing.T, srv *fakeapi.Server) {
+	t.Helper()
+	if err := config.SaveTokens(srv.AccessToken, srv.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// runCLI executes the root command with args and returns what it wrote
+// to stdout and stderr. Environment variables the command loads from
+// ~/.codag are restored afterwards, as if each run were its own process.
+func runCLI(t *testing.T, args ...string) (stdout, stderr string, err error) {
+	t.Helper()
+	resetFlags(rootCmd)
+	defer restoreEnv(authEnvKeys)()
+
+	outR, outW, _ := os.Pipe()
+	errR, errW, _ := os.Pipe()
+	oldOut, oldErr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = outW, errW
+
+	var outBuf, errBuf bytes.Buffer
+	done := make(chan struct{}, 2)
+	go func() { io.Copy(&outBuf, outR); done <- struct{}{} }()
+	go func() { io.Copy(&errBuf, errR); done <- struct{}{} }()
+
+	rootCmd.SetArgs(args)
+	err = rootCmd.Execute()
+	// A failed command skips PersistentPostRun; don't leave its update
+	// check reading Version and the cache under the next test.
+	if updateCheckDone != nil {
+		<-updateCheckDone
+		updateCheckDone = nil
+	}
+
+	outW.Close()
+	errW.Close()
+	<-done
+	<-done
+	os.Stdout, os.Stderr = oldOut, oldErr
+
+	return outBuf.String(), errBuf.String(), err
+}
+
+// resetFlags restores every flag to its default, since cobra keeps flag
+// values between Execute calls.
+func resetFlags(cmd *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		f.Value.Set(f.DefValue)
+		f.Changed = false
+	}
+	cmd.Flags().VisitAll(reset)
+	cmd.PersistentFlags().VisitAll(reset)
+	for _, c := range cmd.Commands() {
+		resetFlags(c)
+	}
+}
+
+// restoreEnv snapshots keys and returns a func that puts them back.
+func restoreEnv(keys []string) func() {
+	saved := map[string]*string{}
+	for _, k := range keys {
+		if v, ok := os.LookupEnv(k); ok {
+			saved[k] = &v
+		} else {
+			saved[k] = nil
+		}
+	}
+	return func() {
+		for k, v := range saved {
+			if v == nil {
+				os.Unsetenv(k)
+			} else {
+				os.Setenv(k, *v)
+			}
+		}
+	}
+}
+
+// withStdin feeds input to the command's stdin for the rest of the test.
+func withStdin(t *testing.T, input string) {
+	t.Helper()
+	f, err := os.CreateTemp(t.TempDir(), "stdin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(input)
+	f.Seek(0, io.SeekStart)
+	old := os.Stdin
+	os.Stdin = f
+	t.Cleanup(func() {
+		os.Stdin = old
+		f.Close()
+	})
+}
+
+// gitCheckout makes the working directory a git checkout with the given
+// remotes, as name/URL pairs.
+func gitCheckout(t *testing.T, remotes ...[2]string) {
+	t.Helper()
+	args := [][]string{{"init", "-q"}}
+	for _, r := range remotes {
+		args = append(args, []string{"remote", "add", r[0], r[1]})
+	}
+	for _, a := range args {
+		if out, err := exec.Command("git", a...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", a, err, out)
+		}
+	}
+}
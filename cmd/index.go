@@ -1,18 +1,38 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/codag-megalith/codag-cli/internal/api"
 	"github.com/codag-megalith/codag-cli/internal/config"
+	"github.com/codag-megalith/codag-cli/internal/project"
 	"github.com/codag-megalith/codag-cli/internal/ui"
 	"github.com/spf13/cobra"
 )
 
 var indexCmd = &cobra.Command{
-	Use:   "index",
-	Short: "Re-index a registered repo",
+	Use:   "index [repo]",
+	Short: "Index new or missed PRs of a registered repo",
+	Long: `Index PRs of a registered repo, merging new signals into its existing
+ones. By default that is every PR merged since the last run; narrow it to
+pick up what a webhook missed:
+
+  codag index --since 2024-05-01 --until 2024-05-31
+  codag index --prs 120-340
+
+--full deletes the repo's signals and re-indexes all of its history, which
+can take hours. You rarely need it.
+
+The repo may be given as owner/name, a repo URL or an ID. Left out, it is
+the current checkout's repo, then the default repo, then the most recently
+registered one. With --owner, --org or --filter it is the most recently
+registered repo that matches.`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		token, err := config.RequireAuth()
 		if err != nil {
@@ -21,67 +41,310 @@ var indexCmd = &cobra.Command{
 			return silent(err)
 		}
 
+		proj, err := loadProject(".")
+		if err != nil {
+			return err
+		}
+		opts, err := backfillOptions(cmd, proj)
+		if err != nil {
+			return err
+		}
 		server := resolveServer(cmd)
 		client := api.NewClient(server, token)
 
-		repoID, _ := cmd.Flags().GetInt("repo")
-		if repoID == 0 {
-			repos, err := client.ListRepos()
-			if err != nil {
-				return handleAPIError(err, server)
-			}
-			if len(repos) == 0 {
-				ui.Error("No repos registered. Run: codag init")
-				return silent(fmt.Errorf("no repos"))
-			}
-			last := repos[len(repos)-1]
-			repoID = last.ID
-			ui.Info(fmt.Sprintf("Using repo #%d (%s/%s)", last.ID, last.Owner, last.Name))
+		repo, err := indexTarget(cmd, client, server, args)
+		if err != nil {
+			return err
 		}
 
-		// Re-indexing is expensive — require explicit confirmation
+		// A full re-index is expensive — require explicit confirmation
 		force, _ := cmd.Flags().GetBool("force")
-		if !force {
-			fmt.Println()
+		if !opts.Incremental && !force {
+			if machineOutput(cmd) {
+				return requireForce(cmd)
+			}
+			fmt.Fprintln(textOut)
 			ui.Warn("Re-indexing deletes all existing data and can take up to hours.")
-			fmt.Println("  This re-processes all PRs from scratch. You usually don't need this —")
-			fmt.Println("  new PRs are indexed automatically via webhooks.")
-			fmt.Println()
-			fmt.Print("  Continue? [y/N] ")
+			fmt.Fprintln(textOut, "  This re-processes all PRs from scratch. You usually don't need this —")
+			fmt.Fprintln(textOut, "  new PRs are indexed automatically via webhooks, and 'codag index'")
+			fmt.Fprintln(textOut, "  without --full picks up any that were missed.")
+			fmt.Fprintln(textOut)
+			fmt.Fprint(textOut, "  Continue? [y/N] ")
 			var answer string
 			fmt.Scanln(&answer)
 			if answer != "y" && answer != "Y" {
 				ui.Info("Cancelled.")
 				return nil
 			}
-			fmt.Println()
+			fmt.Fprintln(textOut)
 		}
 
-		ui.Info("Indexing PR history...")
+		ui.Info(describeBackfill(cmd, opts))
 
-		maxPRs, _ := cmd.Flags().GetInt("max-prs")
-		var maxPRsPtr *int
-		if maxPRs > 0 {
-			maxPRsPtr = &maxPRs
+		backfill, err := client.TriggerBackfill(repo.ID, opts)
+		if errors.Is(err, api.ErrIncrementalUnsupported) {
+			ui.Error("This server can't index incrementally yet.")
+			fmt.Fprintf(os.Stderr, "  To re-index from scratch instead: codag index %s --full\n", repoPath(*repo))
+			return silent(err)
 		}
-
-		result, err := client.TriggerBackfill(repoID, maxPRsPtr)
 		if err != nil {
 			return handleAPIError(err, server)
 		}
 
-		if result.Status == "already_running" {
+		result := indexResult{
+			Repo:           newRepoResult(*repo, config.GetDefaultRepo(), nil),
+			Mode:           backfillMode(opts),
+			AlreadyRunning: backfill.Status == "already_running",
+		}
+		if result.AlreadyRunning {
 			ui.Warn("Indexing already in progress.")
 		}
+		if noWait, _ := cmd.Flags().GetBool("no-wait"); noWait {
+			printNoWait(*repo)
+			return render(cmd, result, nil)
+		}
+
+		stats, err := waitForIndexing(client, repo.ID)
+		if err != nil {
+			return err
+		}
+		result.Completed = stats != nil
+		result.Stats = newStatsResult(stats)
+		return render(cmd, result, nil)
+	},
+}
+
+// indexResult is what init and index report.
+type indexResult struct {
+	Repo           repoResult   `json:"repo" yaml:"repo"`
+	Mode           string       `json:"mode" yaml:"mode"` // full or incremental
+	AlreadyRunning bool         `json:"already_running" yaml:"already_running"`
+	Completed      bool         `json:"completed" yaml:"completed"`
+	Stats          *statsResult `json:"stats" yaml:"stats"`
+}
+
+var indexCancelCmd = &cobra.Command{
+	Use:   "cancel [repo]",
+	Short: "Stop indexing a repo on the server",
+	Long: `Stop indexing a repo on the server, such as a backfill that is taking far
+longer than it should. The repo is chosen as for 'codag index'.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, server, err := authedClient(cmd)
+		if err != nil {
+			return err
+		}
+		repo, err := indexTarget(cmd, client, server, args)
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.CancelBackfill(repo.ID)
+		if errors.Is(err, api.ErrCancelUnsupported) {
+			ui.Error("This server can't cancel indexing yet.")
+			return silent(err)
+		}
+		if err != nil {
+			return handleAPIError(err, server)
+		}
 
-		_, err = pollIndexing(client, repoID)
-		return err
+		result := indexCancelResult{
+			Repo:      newRepoResult(*repo, config.GetDefaultRepo(), nil),
+			Cancelled: resp.Status == "cancelled",
+		}
+		return render(cmd, result, func() {
+			if result.Cancelled {
+				ui.Success(fmt.Sprintf("Stopped indexing %s.", repoPath(*repo)))
+			} else {
+				ui.Info(fmt.Sprintf("%s isn't being indexed.", repoPath(*repo)))
+			}
+		})
 	},
 }
 
+type indexCancelResult struct {
+	Repo      repoResult `json:"repo" yaml:"repo"`
+	Cancelled bool       `json:"cancelled" yaml:"cancelled"`
+}
+
 func init() {
-	indexCmd.Flags().Int("repo", 0, "Repo ID (default: most recent)")
-	indexCmd.Flags().Int("max-prs", 0, "Max PRs to fetch")
-	indexCmd.Flags().BoolP("force", "f", false, "Skip confirmation prompt")
+	indexCmd.AddCommand(indexCancelCmd)
+	addIndexTargetFlags(indexCancelCmd)
+	addServerFlag(indexCancelCmd)
+
+	addIndexTargetFlags(indexCmd)
+	indexCmd.Flags().Int("max-prs", 0, "Max PRs to fetch (default: max_prs in .codag.yml)")
+	// Incremental is the default now; the flag is kept so scripts don't break
+	indexCmd.Flags().Bool("incremental", false, "Index PRs merged since the last run, keeping existing signals")
+	indexCmd.Flags().MarkDeprecated("incremental", "it's the default; leave it out")
+	indexCmd.Flags().String("since", "", "Only index PRs merged on or after this date (YYYY-MM-DD or RFC 3339)")
+	indexCmd.Flags().String("until", "", "Only index PRs merged on or before this date (YYYY-MM-DD or RFC 3339)")
+	indexCmd.Flags().String("prs", "", "Only index these PR numbers: 120-340, 120- or 120")
+	indexCmd.Flags().Bool("full", false, "Delete the repo's signals and re-index all its history")
+	indexCmd.Flags().BoolP("force", "f", false, "Skip confirmation prompt for --full")
+	indexCmd.Flags().Bool("no-wait", false, "Return once indexing has started; follow it with 'codag wait'")
+	for _, scoped := range []string{"incremental", "since", "until", "prs"} {
+		indexCmd.MarkFlagsMutuallyExclusive("full", scoped)
+	}
 	addServerFlag(indexCmd)
 }
+
+func addIndexTargetFlags(cmd *cobra.Command) {
+	cmd.Flags().String("repo", "", "Repo as owner/name, URL or ID (same as the argument)")
+	addRepoFilterFlags(cmd)
+}
+
+// indexTarget finds the repo that index, index cancel and wait act on:
+// the one given, else the most recently registered one matching the filter
+// flags when any are set, else the current checkout's or the default repo,
+// else the most recently registered one. Errors have been reported.
+func indexTarget(cmd *cobra.Command, client *api.Client, server string, args []string) (*api.RepoResponse, error) {
+	arg := firstArg(args)
+	if flag, _ := cmd.Flags().GetString("repo"); flag != "" {
+		arg = flag
+	}
+	opts := repoListOptions(cmd)
+	filtered := opts.Owner != "" || opts.Org != "" || opts.Filter != ""
+	if filtered && arg != "" {
+		ui.Error("--owner, --org and --filter pick a repo when none is given; they can't be used with a repo.")
+		return nil, silent(errors.New("repo and filter flags both given"))
+	}
+
+	if !filtered {
+		repo, err := findRepo(client, server, arg)
+		if err != nil {
+			return nil, err
+		}
+		if repo != nil {
+			if arg == "" {
+				ui.Info(fmt.Sprintf("Using repo #%d (%s)", repo.ID, repoPath(*repo)))
+			}
+			return repo, nil
+		}
+	}
+
+	repo, err := client.MostRecentRepo(opts)
+	if err != nil {
+		return nil, handleAPIError(err, server)
+	}
+	if repo == nil && filtered {
+		ui.Error("No registered repos match the filter.")
+		return nil, silent(fmt.Errorf("no matching repos"))
+	}
+	if repo == nil {
+		ui.Error("No repos registered. Run: codag init")
+		return nil, silent(fmt.Errorf("no repos"))
+	}
+	ui.Info(fmt.Sprintf("Using repo #%d (%s)", repo.ID, repoPath(*repo)))
+	return repo, nil
+}
+
+// printNoWait tells the user how to follow indexing they didn't wait for.
+func printNoWait(repo api.RepoResponse) {
+	ui.Info("Indexing continues on the server.")
+	fmt.Fprintf(textOut, "  Follow it with: codag wait %s\n", repoPath(repo))
+	fmt.Fprintf(textOut, "  Stop it with:   codag index cancel %s\n", repoPath(repo))
+}
+
+// backfillOptions reads index's mode flags. Anything but --full is an
+// incremental backfill. Errors have been reported.
+func backfillOptions(cmd *cobra.Command, proj *project.Config) (api.BackfillOptions, error) {
+	full, _ := cmd.Flags().GetBool("full")
+	opts := api.BackfillOptions{MaxPRs: maxPRsFlag(cmd, proj), Incremental: !full}
+
+	var err error
+	if since, _ := cmd.Flags().GetString("since"); since != "" {
+		if opts.Since, err = parseDateFlag("since", since); err != nil {
+			return opts, err
+		}
+	}
+	if until, _ := cmd.Flags().GetString("until"); until != "" {
+		if opts.Until, err = parseDateFlag("until", until); err != nil {
+			return opts, err
+		}
+		// The server's bound is exclusive and to the second, so move it
+		// past the given time; a bare date includes the whole day.
+		if _, dateOnly := time.Parse(time.DateOnly, until); dateOnly == nil {
+			opts.Until = opts.Until.AddDate(0, 0, 1)
+		} else {
+			opts.Until = opts.Until.Truncate(time.Second).Add(time.Second)
+		}
+	}
+	if !opts.Since.IsZero() && !opts.Until.IsZero() && !opts.Since.Before(opts.Until) {
+		ui.Error("--since must be before --until.")
+		return opts, silent(errors.New("empty date range"))
+	}
+	if prs, _ := cmd.Flags().GetString("prs"); prs != "" {
+		r, err := parsePRRange(prs)
+		if err != nil {
+			ui.Error(fmt.Sprintf("Invalid --prs %q. Use a range like 120-340, 120- or a single PR number.", prs))
+			return opts, silent(err)
+		}
+		opts.PRs = &r
+	}
+	return opts, nil
+}
+
+// parseDateFlag parses a date, taken as local midnight, or an RFC 3339
+// timestamp.
+func parseDateFlag(name, value string) (time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		ui.Error(fmt.Sprintf("Invalid --%s %q. Use a date like 2024-05-01.", name, value))
+		return time.Time{}, silent(err)
+	}
+	return t, nil
+}
+
+// parsePRRange parses "120-340", "120-" or "120".
+func parsePRRange(s string) (api.PRRange, error) {
+	from, to, isRange := strings.Cut(s, "-")
+	var r api.PRRange
+	var err error
+	if r.From, err = strconv.Atoi(from); err != nil || r.From <= 0 {
+		return r, fmt.Errorf("invalid PR number %q", from)
+	}
+	switch {
+	case !isRange:
+		r.To = r.From
+	case to != "":
+		if r.To, err = strconv.Atoi(to); err != nil || r.To < r.From {
+			return r, fmt.Errorf("invalid PR range %q", s)
+		}
+	}
+	return r, nil
+}
+
+func backfillMode(opts api.BackfillOptions) string {
+	if opts.Incremental {
+		return "incremental"
+	}
+	return "full"
+}
+
+// describeBackfill says what index is about to do, in the user's terms.
+func describeBackfill(cmd *cobra.Command, opts api.BackfillOptions) string {
+	if !opts.Incremental {
+		return "Re-indexing all PR history..."
+	}
+	since, _ := cmd.Flags().GetString("since")
+	until, _ := cmd.Flags().GetString("until")
+	desc := "Indexing PRs"
+	if opts.PRs != nil {
+		desc += " " + opts.PRs.String()
+	}
+	switch {
+	case since != "" && until != "":
+		desc += fmt.Sprintf(" merged from %s through %s", since, until)
+	case since != "":
+		desc += " merged since " + since
+	case until != "":
+		desc += " merged through " + until
+	case opts.PRs == nil:
+		desc += " merged since the last run"
+	}
+	return desc + "..."
+}
@@ -2,25 +2,37 @@ package cmd
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
-	"regexp"
 	"strings"
 
 	"github.com/codag-megalith/codag-cli/internal/api"
 	"github.com/codag-megalith/codag-cli/internal/config"
 	"github.com/codag-megalith/codag-cli/internal/mcpconfig"
+	"github.com/codag-megalith/codag-cli/internal/project"
+	"github.com/codag-megalith/codag-cli/internal/remote"
 	"github.com/codag-megalith/codag-cli/internal/ui"
 	"github.com/spf13/cobra"
 )
 
-var sshRemoteRe = regexp.MustCompile(`^git@github\.com:(.+?)(?:\.git)?$`)
-
 var initCmd = &cobra.Command{
-	Use:   "init [github-url]",
+	Use:   "init [repo-url]",
 	Short: "Register a repo and start indexing",
-	Args:  cobra.MaximumNArgs(1),
+	Long: `Register a repo and start indexing its history.
+
+With no argument, the repo is detected from the current checkout's
+remotes: the first of upstream, origin and any listed under remotes: in
+.codag.yml that is already registered with Codag, or else the first that
+exists. In a fork, that is upstream. Use --remote to pick one. Repos on github.com, gitlab.com and bitbucket.org are recognised,
+as are self-hosted servers whose hostname names the provider
+(github.acme.com). Configure others with:
+
+  codag config set git-hosts git.acme.com=github
+
+or a hosts: section in .codag.yml.`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		token, err := config.RequireAuth()
 		if err != nil {
@@ -29,139 +41,210 @@ var initCmd = &cobra.Command{
 			return silent(err)
 		}
 
+		proj, err := loadProject(".")
+		if err != nil {
+			return err
+		}
+		if proj.Found() {
+			ui.Info(fmt.Sprintf("Using project config %s", proj.Path))
+		}
+
 		server := resolveServer(cmd)
 		client := api.NewClient(server, token)
 		scanner := bufio.NewScanner(os.Stdin)
 
-		// Resolve GitHub URL
-		var githubURL string
+		// Resolve the repo
+		hosts := gitHosts(proj)
+		var target remote.Repo
 		var repoRoot string
 
 		if len(args) > 0 {
-			githubURL = args[0]
 			// For explicit URLs, use cwd for .mcp.json
 			repoRoot, _ = os.Getwd()
+			target, err = remote.Resolve(repoRoot, args[0], hosts)
+			if err != nil {
+				printRemoteError(err)
+				return silent(err)
+			}
 		} else {
-			githubURL, repoRoot = detectGitHubURL()
-			if githubURL == "" {
-				ui.Error("Not in a git repo with a GitHub remote.")
-				fmt.Fprintln(os.Stderr, "  Usage: codag init <github-url>")
-				return silent(fmt.Errorf("no github URL detected"))
+			remoteName, _ := cmd.Flags().GetString("remote")
+			var chosen remote.Remote
+			chosen, repoRoot, err = detectRemote(client, server, remoteName, proj, hosts)
+			if err != nil {
+				return err
 			}
-
-			fmt.Printf("Detected: %s\n", githubURL)
-			fmt.Print("Index this repo? [Y/n] ")
-			if scanner.Scan() {
-				answer := strings.TrimSpace(strings.ToLower(scanner.Text()))
-				if answer != "" && answer != "y" {
-					ui.Info("Cancelled.")
-					return nil
+			target = chosen.Repo
+
+			fmt.Fprintf(textOut, "Detected: %s (%s, remote %s)\n", target, target.Provider, chosen.Name)
+			// Machine modes take the default answer
+			if !machineOutput(cmd) {
+				fmt.Fprint(textOut, "Index this repo? [Y/n] ")
+				if scanner.Scan() {
+					answer := strings.TrimSpace(strings.ToLower(scanner.Text()))
+					if answer != "" && answer != "y" {
+						ui.Info("Cancelled.")
+						return nil
+					}
 				}
 			}
 		}
 
 		// Register repo
-		fmt.Println()
-		ui.Info(fmt.Sprintf("Registering %s...", githubURL))
+		fmt.Fprintln(textOut)
+		ui.Info(fmt.Sprintf("Registering %s...", target))
 
-		repo, err := client.RegisterRepo(githubURL)
+		repo, err := client.RegisterRepo(target)
 		if err != nil {
 			return handleAPIError(err, server)
 		}
 
 		// Setup webhook (non-blocking — failures warn but don't abort)
-		setupWebhook(client, repo.ID)
+		setupWebhook(client, repo.ID, target.Provider)
 
+		result := initResult{
+			indexResult: indexResult{Repo: newRepoResult(*repo, config.GetDefaultRepo(), nil), Mode: "full"},
+			MCPConfigs:  []mcpConfigResult{},
+		}
 		if repo.LastIndexedAt != nil {
 			indexed := *repo.LastIndexedAt
 			if len(indexed) > 10 {
 				indexed = indexed[:10]
 			}
 			ui.Warn(fmt.Sprintf("Already indexed (last: %s)", indexed))
-			fmt.Printf("\n  To re-index: codag index --repo %d\n", repo.ID)
+			fmt.Fprintf(textOut, "\n  To pick up new PRs: codag index %s/%s\n", repo.Owner, repo.Name)
 
 			// Still write .mcp.json even if already indexed
-			writeMCPConfig(repoRoot, server)
-			return nil
+			result.AlreadyIndexed = true
+			result.MCPConfigs = writeMCPConfig(repoRoot, server, proj.Editors)
+			return render(cmd, result, nil)
 		}
 
 		ui.Success(fmt.Sprintf("Registered: %s/%s (id: %d)", repo.Owner, repo.Name, repo.ID))
 
 		// Trigger backfill
-		fmt.Println()
+		fmt.Fprintln(textOut)
 		ui.Info("Indexing PR history...")
 
-		maxPRs, _ := cmd.Flags().GetInt("max-prs")
-		var maxPRsPtr *int
-		if maxPRs > 0 {
-			maxPRsPtr = &maxPRs
-		}
-
-		_, err = client.TriggerBackfill(repo.ID, maxPRsPtr)
+		backfill, err := client.TriggerBackfill(repo.ID, api.BackfillOptions{MaxPRs: maxPRsFlag(cmd, proj)})
 		if err != nil {
 			return handleAPIError(err, server)
 		}
+		result.AlreadyRunning = backfill.Status == "already_running"
 
-		// Poll until done
-		_, err = pollIndexing(client, repo.ID)
-		if err != nil {
-			return err
+		if noWait, _ := cmd.Flags().GetBool("no-wait"); noWait {
+			printNoWait(*repo)
+		} else {
+			// Poll until done
+			stats, err := waitForIndexing(client, repo.ID)
+			if err != nil {
+				return err
+			}
+			result.Completed = stats != nil
+			result.Stats = newStatsResult(stats)
 		}
 
 		// Write .mcp.json
-		fmt.Println()
-		writeMCPConfig(repoRoot, server)
+		fmt.Fprintln(textOut)
+		result.MCPConfigs = writeMCPConfig(repoRoot, server, proj.Editors)
 
-		return nil
+		return render(cmd, result, nil)
 	},
 }
 
+// initResult is what init reports: indexResult, plus whether the repo was
+// already indexed (so nothing was started) and the editor configs written.
+type initResult struct {
+	indexResult    `yaml:",inline"`
+	AlreadyIndexed bool              `json:"already_indexed" yaml:"already_indexed"`
+	MCPConfigs     []mcpConfigResult `json:"mcp_configs" yaml:"mcp_configs"`
+}
+
+// mcpConfigResult is an editor config init wrote or checked.
+type mcpConfigResult struct {
+	Editor string `json:"editor" yaml:"editor"`
+	Path   string `json:"path" yaml:"path"`
+	Action string `json:"action" yaml:"action"` // created, updated or unchanged
+}
+
 func init() {
-	initCmd.Flags().Int("max-prs", 0, "Max PRs to fetch (default: 500)")
+	initCmd.Flags().String("remote", "", "Git remote to register (default: upstream, then origin)")
+	initCmd.Flags().Int("max-prs", 0, "Max PRs to fetch (default: max_prs in .codag.yml, or 500)")
+	initCmd.Flags().Bool("no-wait", false, "Return once indexing has started; follow it with 'codag wait'")
 	addServerFlag(initCmd)
 }
 
-// detectGitHubURL runs git commands to find the GitHub remote URL and repo root.
-func detectGitHubURL() (string, string) {
-	// Get repo root
-	rootCmd := exec.Command("git", "rev-parse", "--show-toplevel")
-	rootOut, err := rootCmd.Output()
+// detectRemote picks the remote of the current checkout to register: the
+// one named, or the first preferred remote that is registered with Codag,
+// falling back to the first that exists. It also returns the checkout's
+// root. Errors have been reported.
+func detectRemote(client *api.Client, server, name string, proj *project.Config, hosts remote.Hosts) (remote.Remote, string, error) {
+	out, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
 	if err != nil {
-		return "", ""
+		printRemoteError(errNotInGitRepo)
+		return remote.Remote{}, "", silent(errNotInGitRepo)
 	}
-	repoRoot := strings.TrimSpace(string(rootOut))
+	repoRoot := strings.TrimSpace(string(out))
 
-	// Get remote URL
-	remoteCmd := exec.Command("git", "remote", "get-url", "origin")
-	out, err := remoteCmd.Output()
-	if err != nil {
-		return "", ""
+	if name != "" {
+		repo, err := remote.Detect(repoRoot, name, hosts)
+		if err != nil {
+			printRemoteError(err)
+			return remote.Remote{}, repoRoot, silent(err)
+		}
+		return remote.Remote{Name: name, Repo: repo}, repoRoot, nil
 	}
-	remote := strings.TrimSpace(string(out))
 
-	// Parse SSH format: git@github.com:owner/repo.git
-	if m := sshRemoteRe.FindStringSubmatch(remote); m != nil {
-		return "https://github.com/" + m[1], repoRoot
+	candidates := remote.Candidates(repoRoot, proj.PreferredRemotes(), hosts)
+	if len(candidates) == 0 {
+		// Explain what's wrong with origin, the usual remote
+		_, err := remote.Detect(repoRoot, "origin", hosts)
+		printRemoteError(err)
+		return remote.Remote{}, repoRoot, silent(err)
 	}
-
-	// Parse HTTPS format
-	if strings.Contains(remote, "github.com") {
-		url := strings.TrimSuffix(remote, ".git")
-		return url, repoRoot
+	chosen, _, err := remote.Choose(candidates, func(r remote.Repo) (bool, error) {
+		_, err := client.ResolveRepo(r)
+		if apiErr, ok := err.(*api.APIError); ok && apiErr.StatusCode == 404 {
+			return false, nil
+		}
+		return err == nil, err
+	})
+	if err != nil {
+		return remote.Remote{}, repoRoot, handleAPIError(err, server)
+	}
+	if other, ok := remote.ForkOf(chosen, candidates); ok {
+		ui.Info(fmt.Sprintf("Using %s (%s). %s points at %s; pass --remote %s to use it instead.",
+			chosen.Name, chosen.Repo.Path(), other.Name, other.Repo.Path(), other.Name))
 	}
+	return chosen, repoRoot, nil
+}
 
-	return "", ""
+var errNotInGitRepo = errors.New("not in a git repo")
+
+// printRemoteError explains why a repo couldn't be identified.
+func printRemoteError(err error) {
+	var hostErr *remote.UnknownHostError
+	switch {
+	case errors.Is(err, errNotInGitRepo):
+		ui.Error("Not in a git repo.")
+		fmt.Fprintln(os.Stderr, "  Usage: codag init <repo-url>")
+	case errors.As(err, &hostErr):
+		ui.Error(fmt.Sprintf("Don't know which provider hosts %s.", hostErr.Host))
+		fmt.Fprintf(os.Stderr, "  Run: codag config set git-hosts %s=github   (or gitlab, bitbucket)\n", hostErr.Host)
+	default:
+		ui.Error(fmt.Sprintf("Couldn't identify the repo: %v", err))
+		fmt.Fprintln(os.Stderr, "  Usage: codag init <repo-url>")
+	}
 }
 
-// setupWebhook attempts to create a GitHub webhook for auto-reindexing.
-// Failures are non-fatal — we warn and continue.
-func setupWebhook(client *api.Client, repoID int) {
+// setupWebhook attempts to create a webhook on the repo's provider for
+// auto-reindexing. Failures are non-fatal — we warn and continue.
+func setupWebhook(client *api.Client, repoID int, provider remote.Provider) {
 	webhookResp, err := client.SetupWebhook(repoID)
 	if err != nil {
 		if apiErr, ok := err.(*api.APIError); ok {
 			switch apiErr.StatusCode {
 			case 400:
-				ui.Warn("No GitHub token stored. Webhook skipped.")
+				ui.Warn(fmt.Sprintf("No %s token stored. Webhook skipped.", provider.DisplayName()))
 				fmt.Fprintln(os.Stderr, "  Log in at console.codag.ai to enable auto-reindexing.")
 			case 403:
 				ui.Warn("No admin access to this repo. Webhook skipped.")
@@ -174,21 +257,34 @@ func setupWebhook(client *api.Client, repoID int) {
 		return
 	}
 
+	changes := "PRs"
+	if provider == remote.GitLab {
+		changes = "merge requests"
+	}
 	switch webhookResp.Status {
 	case "created":
-		ui.Success("Webhook created — auto-reindex on push, PRs, and issues")
+		ui.Success(fmt.Sprintf("%s webhook created — auto-reindex on push, %s, and issues", provider.DisplayName(), changes))
 	case "already_exists":
 		ui.Info("Webhook already configured")
 	}
 }
 
-// writeMCPConfig writes MCP configs for all detected editors.
-func writeMCPConfig(repoRoot string, serverURL string) {
+// writeMCPConfig writes MCP configs for the editors listed in .codag.yml,
+// or all detected editors, and returns what it did.
+func writeMCPConfig(repoRoot string, serverURL string, editors []string) []mcpConfigResult {
+	written := []mcpConfigResult{}
 	if repoRoot == "" {
-		return
+		return written
 	}
 
-	results := mcpconfig.WriteAll(repoRoot, serverURL)
+	// Pin the editor's MCP server to a named profile so it uses the same
+	// account as this command. The config may be shared, so `mcp serve`
+	// falls back to the default profile for anyone who doesn't have it.
+	profile := config.ActiveProfile()
+	if profile == config.DefaultProfile {
+		profile = ""
+	}
+	results := mcpconfig.WriteEditors(repoRoot, serverURL, profile, editors)
 
 	if len(results) == 0 {
 		ui.Warn("Could not write MCP config.")
@@ -198,10 +294,11 @@ func writeMCPConfig(repoRoot string, serverURL string) {
   "args": ["mcp", "serve", "."],
   "env": { "CODAG_URL": "%s" }
 }`, serverURL))
-		return
+		return written
 	}
 
 	for _, r := range results {
+		written = append(written, mcpConfigResult{Editor: r.Editor, Path: r.Path, Action: r.Action})
 		switch r.Action {
 		case "created":
 			ui.Success(fmt.Sprintf("Created %s (%s)", r.Path, r.Editor))
@@ -213,8 +310,9 @@ func writeMCPConfig(repoRoot string, serverURL string) {
 	}
 	if strings.Contains(serverURL, "localhost") || strings.Contains(serverURL, "127.0.0.1") {
 		ui.Warn("MCP config points to a local dev server.")
-		fmt.Println("  Re-run 'codag init' without --dev before committing.")
+		fmt.Fprintln(textOut, "  Re-run 'codag init' without --dev before committing.")
 	} else {
-		fmt.Println("  Your coding agent now has access to Codag signals.")
+		fmt.Fprintln(textOut, "  Your coding agent now has access to Codag signals.")
 	}
+	return written
 }
@@ -3,8 +3,12 @@ package cmd
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"os"
 	"os/exec"
 	"runtime"
 	"strings"
@@ -12,40 +16,83 @@ import (
 
 	"github.com/codag-megalith/codag-cli/internal/api"
 	"github.com/codag-megalith/codag-cli/internal/config"
+	"github.com/codag-megalith/codag-cli/internal/httpclient"
 	"github.com/codag-megalith/codag-cli/internal/ui"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 var loginCmd = &cobra.Command{
 	Use:   "login",
 	Short: "Authenticate with Codag",
+	Long: `Authenticate with Codag.
+
+By default this opens a browser to sign in and receives the result on a
+temporary 127.0.0.1 listener. Over SSH, on machines without a display, with
+--no-browser, or with --method device, it uses the device-code flow instead:
+the URL is printed with a code, and a QR code to scan from a phone.
+
+In CI, pipe a personal or service-account token:
+
+  echo "$CODAG_TOKEN" | codag login --with-token
+
+Tokens created with 'codag auth token create' are long-lived and never
+refreshed.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		server := resolveServer(cmd)
 
+		if withToken, _ := cmd.Flags().GetBool("with-token"); withToken {
+			return tokenLogin(server)
+		}
+
 		// Check if existing session is still valid
 		if config.HasAuth() {
 			client := api.NewClient(server, config.GetAccessToken())
-			_, err := client.ListRepos()
+			_, err := client.ListReposPage(api.ListReposOptions{PageSize: 1}, "")
+			var upgradeErr *httpclient.UpgradeRequiredError
+			if errors.As(err, &upgradeErr) {
+				printUpgradeRequired(upgradeErr)
+				return silent(err)
+			}
+			if err == nil && machineOutput(cmd) {
+				ui.Info("Already logged in. Kept existing session.")
+				return nil
+			}
 			if err == nil {
-				fmt.Print("Already logged in. Re-authenticate? [y/N] ")
+				fmt.Fprint(textOut, "Already logged in. Re-authenticate? [y/N] ")
 				var answer string
 				fmt.Scanln(&answer)
 				if answer != "y" && answer != "Y" {
 					ui.Info("Kept existing session.")
 					return nil
 				}
-				fmt.Println()
+				fmt.Fprintln(textOut)
 			} else {
 				// Token expired or invalid — clear and re-auth
 				ui.Warn("Session expired. Logging in again...")
 				config.ClearTokens()
-				fmt.Println()
+				fmt.Fprintln(textOut)
 			}
 		}
 
-		// Device code flow (requires Brain server with JWT configured)
+		method, _ := cmd.Flags().GetString("method")
+		if method != "browser" && method != "device" {
+			ui.Error(fmt.Sprintf("Unknown login method %q. Use browser or device.", method))
+			return silent(fmt.Errorf("invalid --method %q", method))
+		}
+
+		// Both flows require a Brain server with JWT configured
 		isDev, _ := cmd.Flags().GetBool("dev")
-		err := deviceCodeLogin(server, isDev)
+		skipBrowser := browserSkipReason(cmd)
+		var err error
+		if method == "browser" && skipBrowser == "" {
+			err = browserLogin(server)
+			if errors.Is(err, errBrowserUnavailable) {
+				err = deviceCodeLogin(server, isDev, skipBrowser)
+			}
+		} else {
+			err = deviceCodeLogin(server, isDev, skipBrowser)
+		}
 		if err != nil {
 			if apiErr, ok := err.(*api.APIError); ok && apiErr.StatusCode == 501 {
 				ui.Error("Server does not have JWT auth configured. Contact your admin.")
@@ -59,14 +106,79 @@ var loginCmd = &cobra.Command{
 }
 
 func init() {
+	loginCmd.Flags().Bool("with-token", false, "Read an API token from stdin instead of opening a browser")
+	loginCmd.Flags().Bool("no-browser", false, "Don't open a browser; print the URL and a QR code instead")
+	loginCmd.Flags().String("method", "browser", "Login flow: browser or device")
 	addServerFlag(loginCmd)
 }
 
-func deviceCodeLogin(serverURL string, isDev bool) error {
-	httpClient := &http.Client{Timeout: 15 * time.Second}
+// tokenLogin reads a token from stdin, checks it against the server and
+// saves it without a refresh token, so it is used as-is until it expires
+// or is revoked.
+func tokenLogin(serverURL string) error {
+	var raw []byte
+	var err error
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprint(textOut, "Paste your token: ")
+		raw, err = term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(textOut)
+	} else {
+		raw, err = io.ReadAll(io.LimitReader(os.Stdin, 64*1024))
+	}
+	if err != nil {
+		return fmt.Errorf("reading token: %w", err)
+	}
+	token := strings.TrimSpace(string(raw))
+	if token == "" {
+		ui.Error("No token provided on stdin.")
+		fmt.Fprintln(os.Stderr, "  Usage: echo \"$CODAG_TOKEN\" | codag login --with-token")
+		return silent(fmt.Errorf("empty token"))
+	}
 
-	// Step 1: Request device code
-	req, err := http.NewRequest("POST", serverURL+"/api/auth/device", nil)
+	client := api.NewClient(serverURL, token)
+	client.RefreshToken = "" // never refresh API tokens with an old session's refresh token
+	me, err := client.GetMe()
+	if err != nil {
+		if apiErr, ok := err.(*api.APIError); ok && apiErr.StatusCode == 401 {
+			ui.Error("Token was rejected by the server. Check it hasn't expired or been revoked.")
+			return silent(err)
+		}
+		return handleAPIError(err, serverURL)
+	}
+
+	if err := config.SaveTokens(token, ""); err != nil {
+		if errors.Is(err, config.ErrReadOnly) {
+			ui.Error("The env credential store is read-only, so tokens can't be saved.")
+			fmt.Fprintln(os.Stderr, "  Set CODAG_ACCESS_TOKEN instead, or run: codag auth storage file")
+			return silent(err)
+		}
+		return fmt.Errorf("saving token: %w", err)
+	}
+
+	if me.User.GithubLogin != "" {
+		ui.Success(fmt.Sprintf("Logged in as %s (API token)", me.User.GithubLogin))
+	} else {
+		ui.Success("Logged in with API token")
+	}
+	fmt.Fprintf(textOut, "  Token saved to %s\n", config.StoreLocation())
+	return nil
+}
+
+// Device-flow polling bounds. Variables so tests can shorten them.
+var (
+	minDevicePollInterval     = 3 * time.Second
+	defaultDevicePollInterval = 5 * time.Second
+)
+
+// deviceCodeLogin runs the device-code flow. The browser is opened unless
+// skipBrowser gives a reason not to.
+func deviceCodeLogin(serverURL string, isDev bool, skipBrowser string) error {
+	httpClient := httpclient.NewForServer(serverURL, 15*time.Second)
+
+	// Step 1: Request device code, naming this machine so it can be told
+	// apart in `codag auth sessions`
+	deviceBody, _ := json.Marshal(map[string]string{"device_name": deviceName()})
+	req, err := http.NewRequest("POST", serverURL+"/api/auth/device", bytes.NewReader(deviceBody))
 	if err != nil {
 		return fmt.Errorf("creating request: %w", err)
 	}
@@ -74,7 +186,7 @@ func deviceCodeLogin(serverURL string, isDev bool) error {
 
 	resp, err := httpClient.Do(req)
 	if err != nil {
-		ui.Error(fmt.Sprintf("Cannot connect to %s", serverURL))
+		printConnectError(err, serverURL)
 		return silent(fmt.Errorf("connecting to server: %w", err))
 	}
 	defer resp.Body.Close()
@@ -88,32 +200,53 @@ func deviceCodeLogin(serverURL string, isDev bool) error {
 	}
 
 	var deviceResp struct {
-		DeviceCode      string `json:"device_code"`
-		UserCode        string `json:"user_code"`
-		VerificationURI string `json:"verification_uri"`
-		ExpiresIn       int    `json:"expires_in"`
-		Interval        int    `json:"interval"`
+		DeviceCode              string `json:"device_code"`
+		UserCode                string `json:"user_code"`
+		VerificationURI         string `json:"verification_uri"`
+		VerificationURIComplete string `json:"verification_uri_complete"`
+		ExpiresIn               int    `json:"expires_in"`
+		Interval                int    `json:"interval"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&deviceResp); err != nil {
 		return fmt.Errorf("parsing response: %w", err)
 	}
 
 	// Step 2: Display code and open browser
-	verificationURI := deviceResp.VerificationURI
+	verificationURI, completeURI := deviceResp.VerificationURI, deviceResp.VerificationURIComplete
 	if isDev {
-		verificationURI = "http://localhost:3000/device"
+		verificationURI, completeURI = "http://localhost:3000/device", ""
+	}
+	if completeURI == "" {
+		completeURI = withUserCode(verificationURI, deviceResp.UserCode)
 	}
-	fmt.Println()
-	fmt.Printf("  Your code: %s\n", ui.Bold.Render(deviceResp.UserCode))
-	fmt.Println()
+	fmt.Fprintln(textOut)
+	fmt.Fprintf(textOut, "  Your code: %s\n", ui.Bold.Render(deviceResp.UserCode))
+	fmt.Fprintln(textOut)
 
-	if err := openBrowser(verificationURI); err == nil {
-		fmt.Println("  Browser opened. If it didn't open, visit:")
-	} else {
-		fmt.Println("  Open this URL in your browser:")
+	opened := false
+	if skipBrowser == "" {
+		opened = openBrowser(completeURI) == nil
+	}
+	switch {
+	case opened:
+		fmt.Fprintln(textOut, "  Browser opened. If it didn't open, visit:")
+	case skipBrowser != "":
+		fmt.Fprintf(textOut, "  Not opening a browser (%s). On any device, open:\n", skipBrowser)
+	default:
+		fmt.Fprintln(textOut, "  Open this URL in your browser:")
+	}
+	fmt.Fprintf(textOut, "    %s\n", ui.Bold.Render(verificationURI))
+	fmt.Fprintln(textOut)
+
+	// A phone is often the nearest browser when logging in over SSH
+	if !opened && stdoutIsTerminal() {
+		if qr, err := ui.QRCode(completeURI); err == nil {
+			fmt.Fprintln(textOut, "  Or scan this with your phone:")
+			fmt.Fprintln(textOut)
+			fmt.Fprintln(textOut, qr)
+			fmt.Fprintln(textOut)
+		}
 	}
-	fmt.Printf("    %s\n", ui.Bold.Render(verificationURI))
-	fmt.Println()
 
 	// Step 3: Poll for authorization
 	spinner := ui.NewSpinner("Waiting for authorization...")
@@ -121,8 +254,8 @@ func deviceCodeLogin(serverURL string, isDev bool) error {
 	defer spinner.Stop()
 
 	interval := time.Duration(deviceResp.Interval) * time.Second
-	if interval < 3*time.Second {
-		interval = 5 * time.Second
+	if interval < minDevicePollInterval {
+		interval = defaultDevicePollInterval
 	}
 	deadline := time.Now().Add(time.Duration(deviceResp.ExpiresIn) * time.Second)
 
@@ -138,6 +271,12 @@ func deviceCodeLogin(serverURL string, isDev bool) error {
 
 		pollResp, err := httpClient.Do(pollReq)
 		if err != nil {
+			var upgradeErr *httpclient.UpgradeRequiredError
+			if errors.As(err, &upgradeErr) {
+				spinner.Stop()
+				printUpgradeRequired(upgradeErr)
+				return silent(err)
+			}
 			continue // network hiccup, retry
 		}
 
@@ -155,47 +294,14 @@ func deviceCodeLogin(serverURL string, isDev bool) error {
 
 		case 200:
 			// Success!
-			var tokenResp struct {
-				AccessToken  string `json:"access_token"`
-				RefreshToken string `json:"refresh_token"`
-				User         *struct {
-					GithubLogin string `json:"github_login"`
-				} `json:"user"`
-				Subscription *struct {
-					Tier   string `json:"tier"`
-					Status string `json:"status"`
-				} `json:"subscription"`
-			}
-			if err := json.NewDecoder(pollResp.Body).Decode(&tokenResp); err != nil {
-				pollResp.Body.Close()
-				return fmt.Errorf("parsing token response: %w", err)
-			}
+			var tokens loginTokens
+			err := json.NewDecoder(pollResp.Body).Decode(&tokens)
 			pollResp.Body.Close()
-
-			// Save tokens
-			if err := config.SaveTokens(tokenResp.AccessToken, tokenResp.RefreshToken); err != nil {
-				return fmt.Errorf("saving tokens: %w", err)
-			}
-
 			spinner.Stop()
-			login := ""
-			if tokenResp.User != nil {
-				login = tokenResp.User.GithubLogin
-			}
-			if login != "" {
-				ui.Success(fmt.Sprintf("Logged in as %s", login))
-			} else {
-				ui.Success("Logged in successfully")
-			}
-
-			// Show plan
-			if tokenResp.Subscription != nil && tokenResp.Subscription.Tier != "" {
-				tier := strings.ToUpper(tokenResp.Subscription.Tier[:1]) + tokenResp.Subscription.Tier[1:]
-				ui.Keyval("Plan", tier)
+			if err != nil {
+				return fmt.Errorf("parsing token response: %w", err)
 			}
-
-			fmt.Printf("  Tokens saved to %s\n", config.EnvFile)
-			return nil
+			return finishLogin(tokens)
 
 		default:
 			pollResp.Body.Close()
@@ -210,7 +316,108 @@ func deviceCodeLogin(serverURL string, isDev bool) error {
 	return silent(fmt.Errorf("authorization timed out"))
 }
 
-func openBrowser(rawURL string) error {
+// loginTokens is the token response that ends both login flows.
+type loginTokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	User         *struct {
+		GithubLogin string `json:"github_login"`
+	} `json:"user"`
+	Subscription *struct {
+		Tier   string `json:"tier"`
+		Status string `json:"status"`
+	} `json:"subscription"`
+}
+
+// finishLogin saves the tokens from a completed login and reports who is
+// now logged in.
+func finishLogin(tokens loginTokens) error {
+	if err := config.SaveTokens(tokens.AccessToken, tokens.RefreshToken); err != nil {
+		if errors.Is(err, config.ErrReadOnly) {
+			ui.Error("The env credential store is read-only, so tokens can't be saved.")
+			fmt.Fprintln(os.Stderr, "  Run: codag auth storage file   (or keyring)")
+			return silent(err)
+		}
+		return fmt.Errorf("saving tokens: %w", err)
+	}
+
+	login := ""
+	if tokens.User != nil {
+		login = tokens.User.GithubLogin
+	}
+	if login != "" {
+		ui.Success(fmt.Sprintf("Logged in as %s", login))
+	} else {
+		ui.Success("Logged in successfully")
+	}
+
+	// Show plan
+	if tokens.Subscription != nil && tokens.Subscription.Tier != "" {
+		tier := strings.ToUpper(tokens.Subscription.Tier[:1]) + tokens.Subscription.Tier[1:]
+		ui.Keyval("Plan", tier)
+	}
+
+	fmt.Fprintf(textOut, "  Tokens saved to %s\n", config.StoreLocation())
+	return nil
+}
+
+// withUserCode adds the user code to a verification URI, as RFC 8628's
+// verification_uri_complete does, so scanning or opening it skips typing.
+func withUserCode(verificationURI, userCode string) string {
+	u, err := url.Parse(verificationURI)
+	if err != nil {
+		return verificationURI
+	}
+	q := u.Query()
+	q.Set("user_code", userCode)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// browserSkipReason says why the browser shouldn't be opened, or "" if it
+// should.
+func browserSkipReason(cmd *cobra.Command) string {
+	if noBrowser, _ := cmd.Flags().GetBool("no-browser"); noBrowser {
+		return "--no-browser"
+	}
+	if inSSHSession() {
+		return "SSH session"
+	}
+	if runtime.GOOS == "linux" && os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+		return "no display"
+	}
+	return ""
+}
+
+// inSSHSession reports whether we're running over SSH, where a browser
+// would open on the remote machine, if at all.
+func inSSHSession() bool {
+	for _, k := range []string{"SSH_CONNECTION", "SSH_CLIENT", "SSH_TTY"} {
+		if os.Getenv(k) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// stdoutIsTerminal reports whether output goes to a terminal. A variable
+// so tests can force QR code output.
+var stdoutIsTerminal = func() bool {
+	return term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// deviceName identifies this machine in the session list.
+func deviceName() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown host"
+	}
+	return fmt.Sprintf("%s (%s)", host, runtime.GOOS)
+}
+
+// openBrowser opens a URL in the user's browser. A variable so tests can
+// stub it out.
+var openBrowser = func(rawURL string) error {
 	if !strings.HasPrefix(rawURL, "https://") && !strings.HasPrefix(rawURL, "http://") {
 		return fmt.Errorf("refusing to open non-HTTP URL")
 	}
@@ -229,30 +436,102 @@ func openBrowser(rawURL string) error {
 var logoutCmd = &cobra.Command{
 	Use:   "logout",
 	Short: "Sign out and clear saved tokens",
+	Long: `Sign out of this device: revoke its session on the server and clear the
+saved tokens.
+
+If a device is lost, run 'codag logout --all' from another one to revoke
+every session, or revoke just that device with 'codag auth revoke <id>'.`,
+	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		server := resolveServer(cmd)
+		if all, _ := cmd.Flags().GetBool("all"); all {
+			return logoutAll(cmd)
+		}
 
-		// Revoke refresh token on server if possible
-		if rt := config.GetRefreshToken(); rt != "" {
-			httpClient := &http.Client{Timeout: 5 * time.Second}
-			body, _ := json.Marshal(map[string]string{"refresh_token": rt})
-			req, _ := http.NewRequest("POST", server+"/api/auth/logout", bytes.NewReader(body))
-			if req != nil {
-				req.Header.Set("Content-Type", "application/json")
-				httpClient.Do(req) // best-effort, ignore errors
-			}
+		accessToken, refreshToken := config.GetAccessToken(), config.GetRefreshToken()
+		if accessToken == "" && refreshToken == "" {
+			ui.Info("Not logged in.")
+			return render(cmd, logoutResult{}, nil)
+		}
+		// Revoking the session would leave the environment holding dead
+		// tokens, so nothing is done until they're gone from there.
+		if config.ActiveStoreName() == config.StoreEnv {
+			ui.Error("Tokens come from the environment, so codag can't log you out.")
+			printEnvTokensHint()
+			return silent(config.ErrReadOnly)
+		}
+
+		var revokeErr error
+		if refreshToken != "" {
+			revokeErr = api.NewClient(server, accessToken).Logout(refreshToken)
 		}
 
-		// Clear local tokens
 		if err := config.ClearTokens(); err != nil {
-			ui.Warn(fmt.Sprintf("Could not clear tokens: %s", err))
+			ui.Error(fmt.Sprintf("Could not clear tokens: %s", err))
+			return silent(err)
 		}
 
-		ui.Success("Logged out.")
-		return nil
+		result := logoutResult{LoggedOut: true}
+		var apiErr *api.APIError
+		switch {
+		case refreshToken == "":
+			ui.Success("Logged out.")
+			fmt.Fprintln(textOut, "  API tokens stay valid until revoked: codag auth token revoke <id>")
+		case revokeErr == nil:
+			result.SessionRevoked = true
+			ui.Success("Logged out. Session revoked on the server.")
+		case errors.As(revokeErr, &apiErr) && apiErr.StatusCode == 401:
+			result.SessionRevoked = true
+			ui.Success("Logged out. The session had already expired or been revoked.")
+		default:
+			ui.Success("Logged out on this device.")
+			ui.Warn("Could not revoke the session on the server: " + revokeErr.Error())
+			fmt.Fprintln(textOut, "  It stays valid until it expires. To revoke it, log in and run:")
+			fmt.Fprintln(textOut, "    codag auth sessions")
+		}
+		return render(cmd, result, nil)
 	},
 }
 
+// logoutResult reports what logout did. SessionRevoked is set once the
+// server no longer accepts this device's session; SessionsRevoked counts
+// the sessions ended by --all.
+type logoutResult struct {
+	LoggedOut       bool `json:"logged_out" yaml:"logged_out"`
+	SessionRevoked  bool `json:"session_revoked" yaml:"session_revoked"`
+	SessionsRevoked *int `json:"sessions_revoked,omitempty" yaml:"sessions_revoked,omitempty"`
+}
+
+// logoutAll revokes every session on the account. Local tokens are only
+// cleared once the server has confirmed, so a failed attempt can be retried.
+func logoutAll(cmd *cobra.Command) error {
+	client, server, err := authedClient(cmd)
+	if err != nil {
+		return err
+	}
+	n, err := client.RevokeAllSessions()
+	if err != nil {
+		err = handleAPIError(err, server)
+		fmt.Fprintln(os.Stderr, "  No sessions were revoked. Local tokens were kept so you can retry.")
+		return err
+	}
+	ui.Success(fmt.Sprintf("Revoked %d session(s) on the server. Every device is signed out.", n))
+	if err := config.ClearTokens(); errors.Is(err, config.ErrReadOnly) {
+		ui.Warn("Tokens come from the environment, so codag can't clear them.")
+		printEnvTokensHint()
+	} else if err != nil {
+		ui.Warn(fmt.Sprintf("Could not clear tokens: %s", err))
+	}
+	return render(cmd, logoutResult{LoggedOut: true, SessionRevoked: true, SessionsRevoked: &n}, nil)
+}
+
+// printEnvTokensHint tells the user how to drop tokens that come from the
+// environment.
+func printEnvTokensHint() {
+	fmt.Fprintln(os.Stderr, "  Unset CODAG_ACCESS_TOKEN and CODAG_REFRESH_TOKEN in your environment.")
+}
+
 func init() {
+	logoutCmd.Flags().Bool("all", false, "Sign out every device on the account")
 	addServerFlag(logoutCmd)
 }
@@ -0,0 +1,233 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/codag-megalith/codag-cli/internal/api"
+	"github.com/codag-megalith/codag-cli/internal/httpclient"
+	"github.com/codag-megalith/codag-cli/internal/ui"
+)
+
+// errBrowserUnavailable means the browser flow couldn't start, and the
+// device flow should be used instead.
+var errBrowserUnavailable = errors.New("browser login unavailable")
+
+// browserLoginTimeout bounds the wait for the browser to redirect back.
+// A variable so tests can shorten it.
+var browserLoginTimeout = 5 * time.Minute
+
+// listenLoopback opens the callback listener on an ephemeral port. A
+// variable so tests can make it fail.
+var listenLoopback = func() (net.Listener, error) {
+	return net.Listen("tcp", "127.0.0.1:0")
+}
+
+// oauthClientID identifies the CLI to the authorization endpoint.
+const oauthClientID = "codag-cli"
+
+// browserLogin runs the authorization-code flow with PKCE (RFC 7636): the
+// browser signs in and redirects to a listener on 127.0.0.1 with a code,
+// which is exchanged for tokens together with the verifier only this
+// process knows. It returns errBrowserUnavailable if the server predates
+// the flow, the listener can't be opened or the browser can't be launched.
+func browserLogin(serverURL string) error {
+	supported, err := authorizeSupported(serverURL)
+	if err != nil {
+		// The device flow reports the connection problem
+		return errBrowserUnavailable
+	}
+	if !supported {
+		ui.Info("This server doesn't support browser login. Using a device code instead.")
+		return errBrowserUnavailable
+	}
+
+	ln, err := listenLoopback()
+	if err != nil {
+		ui.Warn("Couldn't start a local listener for the browser login. Using a device code instead.")
+		return errBrowserUnavailable
+	}
+	redirectURI := fmt.Sprintf("http://%s/callback", ln.Addr().String())
+
+	verifier, state := randomToken(), randomToken()
+	challenge := sha256.Sum256([]byte(verifier))
+
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", oauthClientID)
+	q.Set("redirect_uri", redirectURI)
+	q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	q.Set("code_challenge_method", "S256")
+	q.Set("state", state)
+	q.Set("device_name", deviceName())
+	authorizeURL := serverURL + "/api/auth/authorize?" + q.Encode()
+
+	results := make(chan callbackResult, 1)
+	srv := &http.Server{
+		Handler:           callbackHandler(state, results),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	if err := openBrowser(authorizeURL); err != nil {
+		ui.Warn("Couldn't open a browser. Using a device code instead.")
+		return errBrowserUnavailable
+	}
+	fmt.Fprintln(textOut)
+	fmt.Fprintln(textOut, "  Browser opened. Finish signing in there. If it didn't open, visit:")
+	fmt.Fprintf(textOut, "    %s\n", authorizeURL)
+	fmt.Fprintln(textOut)
+
+	spinner := ui.NewSpinner("Waiting for the browser...")
+	spinner.Start()
+	defer spinner.Stop()
+
+	var result callbackResult
+	select {
+	case result = <-results:
+	case <-time.After(browserLoginTimeout):
+		spinner.Stop()
+		ui.Error("Timed out waiting for the browser. Please try again, or run: codag login --method device")
+		return silent(fmt.Errorf("authorization timed out"))
+	}
+	if result.err != nil {
+		spinner.Stop()
+		ui.Error(fmt.Sprintf("Authorization failed: %v", result.err))
+		return silent(result.err)
+	}
+
+	tokens, err := exchangeCode(serverURL, result.code, verifier, redirectURI)
+	spinner.Stop()
+	if err != nil {
+		return err
+	}
+	return finishLogin(tokens)
+}
+
+// authorizeSupported reports whether the server has the authorization
+// endpoint. Servers that predate it answer 404 or 405.
+func authorizeSupported(serverURL string) (bool, error) {
+	client := httpclient.NewForServer(serverURL, 10*time.Second)
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	resp, err := client.Get(serverURL + "/api/auth/authorize")
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode != http.StatusNotFound && resp.StatusCode != http.StatusMethodNotAllowed, nil
+}
+
+type callbackResult struct {
+	code string
+	err  error
+}
+
+// callbackHandler receives the redirect from the authorization endpoint.
+// Only the first well-formed callback is used.
+func callbackHandler(state string, results chan<- callbackResult) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /callback", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("state") != state {
+			// Not a response to our request; leave the login waiting
+			http.Error(w, "Invalid state", http.StatusBadRequest)
+			return
+		}
+		var result callbackResult
+		switch {
+		case q.Get("error") != "":
+			msg := q.Get("error")
+			if desc := q.Get("error_description"); desc != "" {
+				msg += ": " + desc
+			}
+			result.err = errors.New(msg)
+		case q.Get("code") == "":
+			result.err = errors.New("no authorization code in callback")
+		default:
+			result.code = q.Get("code")
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if result.err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, callbackPage, "Login failed", html.EscapeString(result.err.Error()))
+		} else {
+			fmt.Fprintf(w, callbackPage, "Logged in to Codag", "You can close this tab and return to the terminal.")
+		}
+		select {
+		case results <- result:
+		default:
+		}
+	})
+	return mux
+}
+
+const callbackPage = `<!doctype html>
+<html><head><meta charset="utf-8"><title>Codag</title></head>
+<body style="font-family: system-ui, sans-serif; text-align: center; margin-top: 4em">
+<h2>%s</h2><p>%s</p>
+</body></html>
+`
+
+// exchangeCode trades an authorization code for tokens.
+func exchangeCode(serverURL, code, verifier, redirectURI string) (loginTokens, error) {
+	body, _ := json.Marshal(map[string]string{
+		"grant_type":    "authorization_code",
+		"client_id":     oauthClientID,
+		"code":          code,
+		"code_verifier": verifier,
+		"redirect_uri":  redirectURI,
+	})
+	req, err := http.NewRequest("POST", serverURL+"/api/auth/token", bytes.NewReader(body))
+	if err != nil {
+		return loginTokens{}, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpclient.NewForServer(serverURL, 15*time.Second).Do(req)
+	if err != nil {
+		var upgradeErr *httpclient.UpgradeRequiredError
+		if errors.As(err, &upgradeErr) {
+			printUpgradeRequired(upgradeErr)
+			return loginTokens{}, silent(err)
+		}
+		printConnectError(err, serverURL)
+		return loginTokens{}, silent(fmt.Errorf("connecting to server: %w", err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		var errResp struct {
+			Detail string `json:"detail"`
+		}
+		json.NewDecoder(resp.Body).Decode(&errResp)
+		return loginTokens{}, &api.APIError{StatusCode: resp.StatusCode, Detail: errResp.Detail}
+	}
+
+	var tokens loginTokens
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return loginTokens{}, fmt.Errorf("parsing token response: %w", err)
+	}
+	return tokens, nil
+}
+
+// randomToken returns 32 random bytes, base64url-encoded: a PKCE verifier
+// (43 characters, within RFC 7636's 43–128) or a state value.
+func randomToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
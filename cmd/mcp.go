@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 
 	codagmcp "github.com/codag-megalith/codag-cli/internal/mcp"
+	"github.com/codag-megalith/codag-cli/internal/project"
 	"github.com/codag-megalith/codag-cli/internal/ui"
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
@@ -24,12 +25,12 @@ var mcpServeCmd = &cobra.Command{
 		// Detect interactive terminal — MCP servers are meant to be launched by an IDE, not run directly
 		if term.IsTerminal(int(os.Stdin.Fd())) {
 			ui.Warn("This command starts an MCP server over stdio (JSON-RPC).")
-			fmt.Println("  It's meant to be launched by your IDE (Cursor, VS Code, etc.), not run directly.")
-			fmt.Println()
-			fmt.Println("  To set up MCP for a project, run:")
-			fmt.Printf("    %s\n", ui.Bold.Render("codag init"))
-			fmt.Println()
-			fmt.Println("  This adds the MCP config to your project so your editor picks it up automatically.")
+			fmt.Fprintln(textOut, "  It's meant to be launched by your IDE (Cursor, VS Code, etc.), not run directly.")
+			fmt.Fprintln(textOut)
+			fmt.Fprintln(textOut, "  To set up MCP for a project, run:")
+			fmt.Fprintf(textOut, "    %s\n", ui.Bold.Render("codag init"))
+			fmt.Fprintln(textOut)
+			fmt.Fprintln(textOut, "  This adds the MCP config to your project so your editor picks it up automatically.")
 			return nil
 		}
 
@@ -47,8 +48,13 @@ var mcpServeCmd = &cobra.Command{
 			return fmt.Errorf("workspace path does not exist: %s", absPath)
 		}
 
-		server := resolveServer(cmd)
-		return codagmcp.Serve(absPath, server, Version)
+		proj, err := project.Load(absPath)
+		if err != nil {
+			return fmt.Errorf("invalid project config: %w", err)
+		}
+
+		server := resolveServerIn(cmd, absPath)
+		return codagmcp.Serve(absPath, server, Version, proj)
 	},
 }
 
@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/codag-megalith/codag-cli/internal/api"
+	"github.com/codag-megalith/codag-cli/internal/ui"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats accepted by --output.
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+var outputFormats = []string{outputTable, outputJSON, outputYAML}
+
+// resultOut receives JSON and YAML documents, and textOut everything
+// printed for people: messages, prompts, progress and tables. In the
+// machine modes textOut is stderr, so nothing a command prints along the
+// way can corrupt the document.
+var (
+	resultOut io.Writer = os.Stdout
+	textOut   io.Writer = os.Stdout
+)
+
+// setupOutput checks --output and prepares the machine-readable modes.
+func setupOutput(cmd *cobra.Command) error {
+	format := outputFormat(cmd)
+	if !slices.Contains(outputFormats, format) {
+		ui.Error(fmt.Sprintf("Unknown output format %q. Use table, json or yaml.", format))
+		return silent(fmt.Errorf("unknown output format %q", format))
+	}
+	machine := format != outputTable
+	resultOut, textOut = os.Stdout, os.Stdout
+	if machine {
+		textOut = os.Stderr
+	}
+	ui.SetOutput(textOut)
+	ui.SetSpinners(!machine)
+	return nil
+}
+
+func outputFormat(cmd *cobra.Command) string {
+	format, _ := cmd.Flags().GetString("output")
+	return format
+}
+
+// machineOutput reports whether results are printed as JSON or YAML.
+// Machine modes never prompt or animate.
+func machineOutput(cmd *cobra.Command) bool {
+	return outputFormat(cmd) != outputTable
+}
+
+// render prints a command's result: with human in table mode, or as a
+// JSON or YAML document. human may be nil if the command has already
+// reported everything as it went. The result types are documented by
+// `codag help output`; keep that in sync when changing them.
+func render(cmd *cobra.Command, result any, human func()) error {
+	switch outputFormat(cmd) {
+	case outputJSON:
+		enc := json.NewEncoder(resultOut)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	case outputYAML:
+		enc := yaml.NewEncoder(resultOut)
+		enc.SetIndent(2)
+		if err := enc.Encode(result); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+	if human != nil {
+		human()
+	}
+	return nil
+}
+
+// requireForce stands in for a confirmation prompt in machine modes,
+// where there is no one to answer it.
+func requireForce(cmd *cobra.Command) error {
+	ui.Error(fmt.Sprintf("Confirmation needed. Pass --force to run this with --output %s.", outputFormat(cmd)))
+	return silent(errors.New("confirmation required"))
+}
+
+// repoResult describes a registered repo.
+type repoResult struct {
+	ID            int          `json:"id" yaml:"id"`
+	Repo          string       `json:"repo" yaml:"repo"`
+	Provider      string       `json:"provider" yaml:"provider"`
+	Host          string       `json:"host" yaml:"host"`
+	URL           string       `json:"url" yaml:"url"`
+	LastIndexedAt *string      `json:"last_indexed_at" yaml:"last_indexed_at"`
+	Default       bool         `json:"default" yaml:"default"`
+	Stats         *statsResult `json:"stats,omitempty" yaml:"stats,omitempty"`
+	StatsError    string       `json:"stats_error,omitempty" yaml:"stats_error,omitempty"`
+}
+
+// statsResult is a repo's indexing progress.
+type statsResult struct {
+	PRsIndexed       int  `json:"prs_indexed" yaml:"prs_indexed"`
+	FilesWithSignals int  `json:"files_with_signals" yaml:"files_with_signals"`
+	TotalSignals     int  `json:"total_signals" yaml:"total_signals"`
+	DangerSignals    int  `json:"danger_signals" yaml:"danger_signals"`
+	Indexing         bool `json:"indexing" yaml:"indexing"`
+}
+
+func newRepoResult(repo api.RepoResponse, defaultRepo string, stats *api.StatsResponse) repoResult {
+	provider := repo.Provider
+	if provider == "" {
+		provider = "github"
+	}
+	return repoResult{
+		ID:            repo.ID,
+		Repo:          repoPath(repo),
+		Provider:      provider,
+		Host:          repoHost(repo),
+		URL:           repoURL(repo),
+		LastIndexedAt: repo.LastIndexedAt,
+		Default:       isDefaultRepo(repo, defaultRepo),
+		Stats:         newStatsResult(stats),
+	}
+}
+
+func newStatsResult(stats *api.StatsResponse) *statsResult {
+	if stats == nil {
+		return nil
+	}
+	return &statsResult{
+		PRsIndexed:       stats.PRsIndexed,
+		FilesWithSignals: stats.FilesWithSignals,
+		TotalSignals:     stats.TotalSignals,
+		DangerSignals:    stats.DangerSignals,
+		Indexing:         stats.Indexing,
+	}
+}
+
+var outputHelpCmd = &cobra.Command{
+	Use:   "output",
+	Short: "Machine-readable output and its schema",
+	Long: `Every command accepts --output (-o) table, json or yaml. table, the
+default, is for people. json and yaml print one document on stdout for
+scripts; messages and warnings go to stderr, spinners are off and
+commands never prompt: those that ask for confirmation need --force,
+and init registers the detected repo. A command that fails exits non-zero
+and prints nothing on stdout, except as noted below.
+
+Field names are stable. New fields may be added; existing ones won't be
+renamed or removed. Timestamps are RFC 3339 strings, and null where
+there is none.
+
+A repo, where one appears below:
+  id               number
+  repo             string   path on its host, e.g. "octo/widgets"
+  provider         string   github, gitlab or bitbucket
+  host             string
+  url              string
+  last_indexed_at  string|null
+  default          bool     set with 'codag repo set-default'
+  stats            object   absent in 'repo list', or if stats couldn't be fetched
+    prs_indexed, files_with_signals, total_signals, danger_signals  number
+    indexing       bool
+  stats_error      string   why stats couldn't be fetched, in status and
+                            repo show; absent otherwise
+
+codag version       {version, commit, build_date}
+codag account       {user, email, plan, cancel_at_period_end, repos (number),
+                     orgs (list of names)}
+codag status        {repos: [repo]}; with --watch, one per refresh: a line of
+                    JSON each, or a YAML stream
+codag repo list     {repos: [repo]}
+codag repo show     repo
+codag repo remove   {removed: repo, default_cleared}
+codag repo set-default
+                    {default_repo: repo|null}
+codag init          {repo, mode, already_indexed, already_running, completed,
+                     stats|null, mcp_configs: [{editor, path, action}]}
+codag index         {repo, mode, already_running, completed, stats|null}
+                    mode is full or incremental. completed is false with
+                    --no-wait, or if indexing was still running when the
+                    CLI stopped waiting.
+codag index cancel  {repo, cancelled}; cancelled is false if the repo
+                    wasn't being indexed
+codag wait          {repo, was_indexing, stats}; fails if indexing is
+                    cancelled or outlasts --timeout
+codag auth status   {profile, server, store, store_location, token_type
+                     ("session" or "api_token"), user, subject, scopes,
+                     issued_at, expires_at, expired, refreshable}; exits
+                    non-zero after printing if expired and not refreshable
+codag auth sessions {sessions: [{id, device, client, created_at,
+                     last_used_at, current}]}
+codag auth token list
+                    {tokens: [{id, name, kind, org, prefix, created_at,
+                     last_used_at, expires_at}]}
+codag auth token create
+                    a token as above, plus token (the secret)
+codag auth token revoke
+                    {revoked (token id)}
+codag auth revoke   {revoked (session id), current}; current is true if it
+                    was this device's session, whose tokens were cleared
+codag auth storage  {store, location, available (list of store names)},
+                    after switching if a store is given
+codag logout        {logged_out, session_revoked}; logged_out is false if
+                    there was no session. With --all, also sessions_revoked
+                    (number)
+codag config list   {settings: [{name, value, source, origin}]}; secrets
+                    are masked and unset values are ""
+codag config get    {name, value, source, origin}
+codag config set, codag config unset
+                    {name, value, source, origin}: the effective setting
+                    afterwards
+codag profile list  {current, profiles: [{name, server}]}
+codag profile use, codag profile add
+                    {profile, server, active}; active is true if it is now
+                    the current profile
+codag profile remove
+                    {removed (profile name)}
+
+login and upgrade print no document; check the exit status. mcp serve
+speaks MCP on stdout.`,
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
@@ -8,14 +9,20 @@ import (
 	"github.com/codag-megalith/codag-cli/internal/ui"
 )
 
-const (
-	pollInterval   = 5 * time.Second
-	pollTimeout    = 30 * time.Minute
+// Polling cadence for indexing progress. Variables so tests can shorten them.
+var (
+	pollInterval    = 5 * time.Second
+	pollTimeout     = 30 * time.Minute
 	pollGracePeriod = 2 * time.Minute
 )
 
-// pollIndexing polls /api/stats until indexing completes or timeout.
-func pollIndexing(client *api.Client, repoID int) (*api.StatsResponse, error) {
+// errIndexingTimedOut is returned by pollIndexing when indexing is still
+// running at the timeout. It hasn't been reported.
+var errIndexingTimedOut = errors.New("indexing still running")
+
+// pollIndexing polls /api/stats until indexing completes or timeout. A
+// cancelled run is reported and returned as a silent error.
+func pollIndexing(client *api.Client, repoID int, timeout time.Duration) (*api.StatsResponse, error) {
 	spin := ui.NewSpinner("Waiting for indexing...")
 	spin.Start()
 	defer spin.Stop()
@@ -29,10 +36,8 @@ func pollIndexing(client *api.Client, repoID int) (*api.StatsResponse, error) {
 		stats, err := client.GetStats(repoID)
 		if err != nil {
 			// Transient errors during polling are OK — keep trying
-			if time.Since(start) > pollTimeout {
-				spin.Stop()
-				ui.Warn("Indexing is taking a while. Check back with: codag status")
-				return nil, nil
+			if time.Since(start) > timeout {
+				return nil, errIndexingTimedOut
 			}
 			continue
 		}
@@ -46,18 +51,23 @@ func pollIndexing(client *api.Client, repoID int) (*api.StatsResponse, error) {
 		}
 
 		// Break conditions:
-		// 1. Indexing done with signals
-		// 2. Indexing done with 0 signals after grace period (avoids infinite loop)
+		// 1. Indexing cancelled
+		// 2. Indexing done with signals
+		// 3. Indexing done with 0 signals after grace period (avoids infinite loop)
 		if !stats.Indexing {
+			if stats.Cancelled {
+				spin.Stop()
+				ui.Error("Indexing was cancelled before it finished.")
+				printIndexStats(stats)
+				return stats, silent(errors.New("indexing cancelled"))
+			}
 			if stats.TotalSignals > 0 || time.Since(start) > pollGracePeriod {
 				break
 			}
 		}
 
-		if time.Since(start) > pollTimeout {
-			spin.Stop()
-			ui.Warn("Indexing is taking a while. Check back with: codag status")
-			return nil, nil
+		if time.Since(start) > timeout {
+			return nil, errIndexingTimedOut
 		}
 	}
 
@@ -70,9 +80,25 @@ func pollIndexing(client *api.Client, repoID int) (*api.StatsResponse, error) {
 	}
 
 	ui.Success("Done!")
+	printIndexStats(stats)
+
+	return stats, nil
+}
+
+// waitForIndexing is pollIndexing for commands that started indexing:
+// running out of time isn't an error, since indexing carries on.
+func waitForIndexing(client *api.Client, repoID int) (*api.StatsResponse, error) {
+	stats, err := pollIndexing(client, repoID, pollTimeout)
+	if errors.Is(err, errIndexingTimedOut) {
+		ui.Warn("Indexing is taking a while. Check back with: codag wait")
+		return nil, nil
+	}
+	return stats, err
+}
+
+// printIndexStats prints what indexing produced.
+func printIndexStats(stats *api.StatsResponse) {
 	ui.Keyval("PRs indexed", fmt.Sprintf("%d", stats.PRsIndexed))
 	ui.Keyval("Files w/ signals", fmt.Sprintf("%d", stats.FilesWithSignals))
 	ui.Keyval("Total signals", fmt.Sprintf("%d (%d danger)", stats.TotalSignals, stats.DangerSignals))
-
-	return stats, nil
 }
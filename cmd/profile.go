@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/codag-megalith/codag-cli/internal/api"
+	"github.com/codag-megalith/codag-cli/internal/config"
+	"github.com/codag-megalith/codag-cli/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage named profiles for multiple servers and accounts",
+	Long: `Each profile has its own server URL, tokens and defaults.
+
+The profile in use is picked from --profile, then CODAG_PROFILE, then the
+one chosen with 'codag profile use'. Editors can set CODAG_PROFILE in the
+MCP server env so 'codag mcp serve' uses the right account. If that
+profile doesn't exist on this machine, 'codag mcp serve' warns and uses the
+default profile.`,
+}
+
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List profiles",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		names, err := config.ListProfiles()
+		if err != nil {
+			return err
+		}
+
+		flag, _ := cmd.Flags().GetString("profile")
+		current := config.SelectProfile(flag)
+
+		result := profileListResult{Current: current, Profiles: []profileResult{}}
+		for _, name := range names {
+			result.Profiles = append(result.Profiles, profileResult{Name: name, Server: profileServer(name)})
+		}
+
+		return render(cmd, result, func() {
+			fmt.Fprintln(textOut)
+			for _, p := range result.Profiles {
+				marker := " "
+				label := p.Name
+				if p.Name == current {
+					marker = ui.Green.Render("*")
+					label = ui.Bold.Render(p.Name)
+				}
+				fmt.Fprintf(textOut, "  %s %s  %s\n", marker, label, ui.Dim.Render(p.Server))
+			}
+			fmt.Fprintln(textOut)
+		})
+	},
+}
+
+type profileListResult struct {
+	Current  string          `json:"current" yaml:"current"`
+	Profiles []profileResult `json:"profiles" yaml:"profiles"`
+}
+
+type profileResult struct {
+	Name   string `json:"name" yaml:"name"`
+	Server string `json:"server" yaml:"server"`
+}
+
+var profileUseCmd = &cobra.Command{
+	Use:   "use <name>",
+	Short: "Set the profile used by default",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := config.SetCurrentProfile(args[0]); err != nil {
+			ui.Error(err.Error())
+			return silent(err)
+		}
+		ui.Success(fmt.Sprintf("Using profile %s", args[0]))
+		return render(cmd, newProfileChangeResult(args[0], true), nil)
+	},
+}
+
+// profileChangeResult describes a profile that was selected or created.
+// Active is set when it's now the current profile.
+type profileChangeResult struct {
+	Profile string `json:"profile" yaml:"profile"`
+	Server  string `json:"server" yaml:"server"`
+	Active  bool   `json:"active" yaml:"active"`
+}
+
+func newProfileChangeResult(name string, active bool) profileChangeResult {
+	return profileChangeResult{Profile: name, Server: profileServer(name), Active: active}
+}
+
+// profileServer returns the server a profile talks to.
+func profileServer(name string) string {
+	settings, _ := config.ProfileSettings(name)
+	if server := settings["CODAG_SERVER_URL"]; server != "" {
+		return server
+	}
+	return api.DefaultServer
+}
+
+var profileAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Create a profile",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		server, _ := cmd.Flags().GetString("server")
+		if err := config.AddProfile(name, server); err != nil {
+			ui.Error(err.Error())
+			return silent(err)
+		}
+		ui.Success(fmt.Sprintf("Created profile %s", name))
+
+		use, _ := cmd.Flags().GetBool("use")
+		if use {
+			if err := config.SetCurrentProfile(name); err != nil {
+				return err
+			}
+			ui.Info(fmt.Sprintf("Using profile %s", name))
+			fmt.Fprintln(textOut, "  Next: codag login")
+		} else {
+			fmt.Fprintf(textOut, "  Next: codag login --profile %s\n", name)
+		}
+		return render(cmd, newProfileChangeResult(name, use), nil)
+	},
+}
+
+var profileRemoveCmd = &cobra.Command{
+	Use:     "remove <name>",
+	Aliases: []string{"rm"},
+	Short:   "Delete a profile and its saved tokens",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := config.RemoveProfile(args[0]); err != nil {
+			ui.Error(err.Error())
+			return silent(err)
+		}
+		ui.Success(fmt.Sprintf("Removed profile %s", args[0]))
+		return render(cmd, profileRemoveResult{Removed: args[0]}, nil)
+	},
+}
+
+type profileRemoveResult struct {
+	Removed string `json:"removed" yaml:"removed"`
+}
+
+func init() {
+	profileAddCmd.Flags().String("server", "", "API server URL for this profile")
+	profileAddCmd.Flags().Bool("use", false, "Make this the current profile")
+	profileCmd.AddCommand(profileListCmd)
+	profileCmd.AddCommand(profileUseCmd)
+	profileCmd.AddCommand(profileAddCmd)
+	profileCmd.AddCommand(profileRemoveCmd)
+}
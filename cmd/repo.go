@@ -0,0 +1,424 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/codag-megalith/codag-cli/internal/api"
+	"github.com/codag-megalith/codag-cli/internal/config"
+	"github.com/codag-megalith/codag-cli/internal/remote"
+	"github.com/codag-megalith/codag-cli/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var repoCmd = &cobra.Command{
+	Use:   "repo",
+	Short: "Manage registered repos",
+	Long: `List, inspect and remove registered repos.
+
+Wherever a repo is expected it can be given as owner/name, a repo URL or a
+numeric ID. Left out, it is the repo of the current checkout, then the
+default repo set with 'codag repo set-default'.`,
+}
+
+var repoListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List registered repos",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, server, err := authedClient(cmd)
+		if err != nil {
+			return err
+		}
+
+		machine := machineOutput(cmd)
+		defaultRepo := config.GetDefaultRepo()
+		result := repoListResult{Repos: []repoResult{}}
+		err = client.EachRepoPage(repoListOptions(cmd), func(repos []api.RepoResponse) error {
+			for _, repo := range repos {
+				result.Repos = append(result.Repos, newRepoResult(repo, defaultRepo, nil))
+				if machine {
+					continue
+				}
+				if len(result.Repos) == 1 {
+					fmt.Fprintln(textOut)
+				}
+				note := repoHost(repo)
+				if isDefaultRepo(repo, defaultRepo) {
+					note += " · default"
+				}
+				fmt.Fprintf(textOut, "  %s  %s  %s\n", ui.Bold.Render(fmt.Sprintf("#%d", repo.ID)), repoPath(repo), ui.Dim.Render(note))
+				ui.Keyval("Last indexed", lastIndexed(repo.LastIndexedAt))
+				fmt.Fprintln(textOut)
+			}
+			return nil
+		})
+		if err != nil {
+			return handleAPIError(err, server)
+		}
+		return render(cmd, result, func() {
+			if len(result.Repos) == 0 {
+				ui.Info("No repos registered. Run: codag init")
+			}
+		})
+	},
+}
+
+type repoListResult struct {
+	Repos []repoResult `json:"repos" yaml:"repos"`
+}
+
+var repoShowCmd = &cobra.Command{
+	Use:   "show [repo]",
+	Short: "Show a registered repo and its stats",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, server, err := authedClient(cmd)
+		if err != nil {
+			return err
+		}
+		repo, err := requireRepo(client, server, firstArg(args))
+		if err != nil {
+			return err
+		}
+
+		defaultRepo := config.GetDefaultRepo()
+		stats, err := client.GetStats(repo.ID)
+		result := newRepoResult(repo, defaultRepo, stats)
+		if err != nil {
+			result.StatsError = err.Error()
+		}
+		return render(cmd, result, func() {
+			fmt.Fprintln(textOut)
+			fmt.Fprintf(textOut, "  %s\n", ui.Bold.Render(repoPath(repo)))
+			ui.Keyval("ID", strconv.Itoa(repo.ID))
+			ui.Keyval("URL", repoURL(repo))
+			if repo.Provider != "" {
+				ui.Keyval("Provider", repo.Provider)
+			}
+			ui.Keyval("Last indexed", lastIndexed(repo.LastIndexedAt))
+			if isDefaultRepo(repo, defaultRepo) {
+				ui.Keyval("Default", "yes")
+			}
+			if stats != nil {
+				ui.Keyval("PRs", strconv.Itoa(stats.PRsIndexed))
+				ui.Keyval("Files w/ signals", strconv.Itoa(stats.FilesWithSignals))
+				ui.Keyval("Signals", fmt.Sprintf("%d (%d danger)", stats.TotalSignals, stats.DangerSignals))
+				if stats.Indexing {
+					fmt.Fprintf(textOut, "  %s\n", ui.Yellow.Render("Status: indexing..."))
+				}
+			} else {
+				fmt.Fprintf(textOut, "  %s\n", ui.Yellow.Render("Stats unavailable: "+result.StatsError))
+			}
+			fmt.Fprintln(textOut)
+		})
+	},
+}
+
+var repoRemoveCmd = &cobra.Command{
+	Use:     "remove <repo>",
+	Aliases: []string{"rm"},
+	Short:   "Unregister a repo and delete its indexed data",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, server, err := authedClient(cmd)
+		if err != nil {
+			return err
+		}
+		repo, err := requireRepo(client, server, args[0])
+		if err != nil {
+			return err
+		}
+
+		if force, _ := cmd.Flags().GetBool("force"); !force {
+			if machineOutput(cmd) {
+				return requireForce(cmd)
+			}
+			fmt.Fprintln(textOut)
+			ui.Warn(fmt.Sprintf("This unregisters %s (#%d) and deletes its signals.", repoPath(repo), repo.ID))
+			fmt.Fprintln(textOut, "  Editors using it will stop getting briefs until it is registered again.")
+			fmt.Fprintln(textOut)
+			fmt.Fprint(textOut, "  Continue? [y/N] ")
+			var answer string
+			fmt.Scanln(&answer)
+			if answer != "y" && answer != "Y" {
+				ui.Info("Cancelled.")
+				return nil
+			}
+			fmt.Fprintln(textOut)
+		}
+
+		defaultRepo := config.GetDefaultRepo()
+		if err := client.DeleteRepo(repo.ID); err != nil {
+			return handleAPIError(err, server)
+		}
+		ui.Success(fmt.Sprintf("Removed %s (#%d)", repoPath(repo), repo.ID))
+
+		result := repoRemoveResult{Removed: newRepoResult(repo, defaultRepo, nil)}
+		if result.Removed.Default {
+			if err := config.RemoveEnvVar("CODAG_DEFAULT_REPO"); err != nil {
+				ui.Warn(fmt.Sprintf("Could not clear the default repo: %s", err))
+			} else {
+				result.DefaultCleared = true
+				ui.Info("It was the default repo; no default is set now.")
+			}
+		}
+		return render(cmd, result, nil)
+	},
+}
+
+type repoRemoveResult struct {
+	Removed        repoResult `json:"removed" yaml:"removed"`
+	DefaultCleared bool       `json:"default_cleared" yaml:"default_cleared"`
+}
+
+var repoSetDefaultCmd = &cobra.Command{
+	Use:   "set-default [repo]",
+	Short: "Set the repo used outside a checkout",
+	Long: `Set the repo commands use when none is given and the current directory
+isn't a checkout of a registered repo. With no argument, the current
+checkout's repo becomes the default. The default is saved per profile.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if unset, _ := cmd.Flags().GetBool("unset"); unset {
+			if err := config.RemoveEnvVar("CODAG_DEFAULT_REPO"); err != nil {
+				return fmt.Errorf("saving setting: %w", err)
+			}
+			ui.Success("Default repo cleared")
+			return render(cmd, repoDefaultResult{}, nil)
+		}
+
+		client, server, err := authedClient(cmd)
+		if err != nil {
+			return err
+		}
+		// With no argument only the checkout counts; falling back to the
+		// current default would be a no-op.
+		var repo *api.RepoResponse
+		if len(args) > 0 {
+			repo, err = lookupRepo(client, server, args[0])
+		} else {
+			repo, err = checkoutRepo(client, server)
+		}
+		if err != nil {
+			return err
+		}
+		if repo == nil {
+			ui.Error("Not in a checkout of a registered repo.")
+			fmt.Fprintln(os.Stderr, "  Usage: codag repo set-default <owner/name>")
+			return silent(errors.New("no repo"))
+		}
+
+		// The ID is saved since owner/name can match repos on several hosts
+		if err := config.SaveEnvVar("CODAG_DEFAULT_REPO", strconv.Itoa(repo.ID)); err != nil {
+			if errors.Is(err, config.ErrReadOnly) {
+				ui.Error(err.Error())
+				return silent(err)
+			}
+			return fmt.Errorf("saving setting: %w", err)
+		}
+		ui.Success(fmt.Sprintf("Default repo: %s (#%d)", repoPath(*repo), repo.ID))
+		result := newRepoResult(*repo, repoPath(*repo), nil)
+		return render(cmd, repoDefaultResult{DefaultRepo: &result}, nil)
+	},
+}
+
+type repoDefaultResult struct {
+	DefaultRepo *repoResult `json:"default_repo" yaml:"default_repo"`
+}
+
+func init() {
+	addRepoFilterFlags(repoListCmd)
+	repoRemoveCmd.Flags().BoolP("force", "f", false, "Skip confirmation prompt")
+	repoSetDefaultCmd.Flags().Bool("unset", false, "Clear the default repo")
+	for _, c := range []*cobra.Command{repoListCmd, repoShowCmd, repoRemoveCmd, repoSetDefaultCmd} {
+		addServerFlag(c)
+		repoCmd.AddCommand(c)
+	}
+}
+
+// findRepo resolves the repo a command acts on. arg may be a numeric ID,
+// "owner/name" or a repo URL. With no arg it is the current checkout's
+// repo, then the default repo; if neither is set, findRepo returns nil so
+// the caller can choose a fallback. Errors have been reported.
+func findRepo(client *api.Client, server, arg string) (*api.RepoResponse, error) {
+	if arg != "" {
+		return lookupRepo(client, server, arg)
+	}
+	repo, err := checkoutRepo(client, server)
+	if err != nil || repo != nil {
+		return repo, err
+	}
+	if def := config.GetDefaultRepo(); def != "" {
+		return lookupRepo(client, server, def)
+	}
+	return nil, nil
+}
+
+// requireRepo is findRepo for commands that have no fallback.
+func requireRepo(client *api.Client, server, arg string) (api.RepoResponse, error) {
+	repo, err := findRepo(client, server, arg)
+	if err != nil {
+		return api.RepoResponse{}, err
+	}
+	if repo == nil {
+		ui.Error("No repo given, and this isn't a checkout of a registered repo.")
+		fmt.Fprintln(os.Stderr, "  Pass owner/name, or set one with: codag repo set-default <owner/name>")
+		return api.RepoResponse{}, silent(errors.New("no repo"))
+	}
+	return *repo, nil
+}
+
+// lookupRepo finds a registered repo by ID, URL or owner/name.
+func lookupRepo(client *api.Client, server, arg string) (*api.RepoResponse, error) {
+	if id, err := strconv.Atoi(strings.TrimPrefix(arg, "#")); err == nil {
+		repo, err := client.GetRepo(id)
+		if apiErr, ok := err.(*api.APIError); ok && apiErr.StatusCode == 404 {
+			ui.Error(fmt.Sprintf("No repo #%d.", id))
+			fmt.Fprintln(os.Stderr, "  List repos with: codag repo list")
+			return nil, silent(err)
+		}
+		if err != nil {
+			return nil, handleAPIError(err, server)
+		}
+		return repo, nil
+	}
+
+	if strings.Contains(arg, "://") || strings.Contains(arg, "@") {
+		proj, err := loadProject(".")
+		if err != nil {
+			return nil, err
+		}
+		target, err := remote.Resolve(".", arg, gitHosts(proj))
+		if err != nil {
+			printRemoteError(err)
+			return nil, silent(err)
+		}
+		repo, err := client.ResolveRepo(target)
+		if apiErr, ok := err.(*api.APIError); ok && apiErr.StatusCode == 404 {
+			ui.Error(fmt.Sprintf("%s is not registered.", target))
+			fmt.Fprintf(os.Stderr, "  Register it with: codag init %s\n", target)
+			return nil, silent(err)
+		}
+		if err != nil {
+			return nil, handleAPIError(err, server)
+		}
+		return repo, nil
+	}
+
+	repoPathArg := strings.Trim(arg, "/")
+	if !strings.Contains(repoPathArg, "/") {
+		ui.Error(fmt.Sprintf("%q is not a repo. Use owner/name, a repo URL or an ID.", arg))
+		return nil, silent(fmt.Errorf("invalid repo %q", arg))
+	}
+	found, err := client.FindRepos(repoPathArg)
+	if err != nil {
+		return nil, handleAPIError(err, server)
+	}
+	switch len(found) {
+	case 0:
+		ui.Error(fmt.Sprintf("No registered repo %s.", repoPathArg))
+		fmt.Fprintln(os.Stderr, "  List repos with: codag repo list")
+		return nil, silent(fmt.Errorf("repo %s not found", repoPathArg))
+	case 1:
+		return &found[0], nil
+	}
+	ui.Error(fmt.Sprintf("%s is registered on more than one host:", repoPathArg))
+	for _, r := range found {
+		fmt.Fprintf(os.Stderr, "  #%d  %s\n", r.ID, repoURL(r))
+	}
+	fmt.Fprintln(os.Stderr, "  Pass the URL or ID instead.")
+	return nil, silent(fmt.Errorf("ambiguous repo %s", repoPathArg))
+}
+
+// checkoutRepo returns the registered repo of the checkout in the working
+// directory, chosen among its remotes as init does, or nil if it isn't a
+// checkout or none of its remotes is registered.
+func checkoutRepo(client *api.Client, server string) (*api.RepoResponse, error) {
+	out, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return nil, nil
+	}
+	repoRoot := strings.TrimSpace(string(out))
+	proj, err := loadProject(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := remote.Candidates(repoRoot, proj.PreferredRemotes(), gitHosts(proj))
+	var found *api.RepoResponse
+	_, registered, err := remote.Choose(candidates, func(r remote.Repo) (bool, error) {
+		repo, err := client.ResolveRepo(r)
+		if apiErr, ok := err.(*api.APIError); ok && apiErr.StatusCode == 404 {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		found = repo
+		return true, nil
+	})
+	if registered {
+		return found, nil
+	}
+	if err != nil {
+		return nil, handleAPIError(err, server)
+	}
+	return nil, nil
+}
+
+// isDefaultRepo reports whether repo is the one set with set-default.
+func isDefaultRepo(repo api.RepoResponse, defaultRepo string) bool {
+	if defaultRepo == "" {
+		return false
+	}
+	if id, err := strconv.Atoi(strings.TrimPrefix(defaultRepo, "#")); err == nil {
+		return id == repo.ID
+	}
+	return strings.EqualFold(strings.Trim(defaultRepo, "/"), repoPath(repo))
+}
+
+func repoPath(repo api.RepoResponse) string {
+	return repo.Owner + "/" + repo.Name
+}
+
+// repoURL returns the repo's web URL. Servers that predate other
+// providers only send github_url.
+func repoURL(repo api.RepoResponse) string {
+	if repo.URL != "" {
+		return repo.URL
+	}
+	return repo.GithubURL
+}
+
+// repoHost returns the host a repo lives on, for display.
+func repoHost(repo api.RepoResponse) string {
+	if repo.Host != "" {
+		return repo.Host
+	}
+	return "github.com"
+}
+
+// lastIndexed formats a repo's last index date.
+func lastIndexed(at *string) string {
+	if at == nil {
+		return "never"
+	}
+	indexed := *at
+	if len(indexed) > 10 {
+		indexed = indexed[:10]
+	}
+	return indexed
+}
+
+func firstArg(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return args[0]
+}
@@ -0,0 +1,7 @@
+package cmd
+
+import "os"
+
+// notifyResize does nothing: Windows has no resize signal, so the
+// dashboard picks up a new size at its next refresh.
+func notifyResize(ch chan<- os.Signal) {}
@@ -9,11 +9,19 @@ import (
 
 	"github.com/codag-megalith/codag-cli/internal/api"
 	"github.com/codag-megalith/codag-cli/internal/config"
+	"github.com/codag-megalith/codag-cli/internal/httpclient"
+	"github.com/codag-megalith/codag-cli/internal/project"
+	"github.com/codag-megalith/codag-cli/internal/remote"
 	"github.com/codag-megalith/codag-cli/internal/ui"
 	"github.com/spf13/cobra"
 )
 
-var updateCheckDone = make(chan struct{})
+var updateCheckDone chan struct{}
+
+// interrupts feeds the default SIGINT/SIGTERM handler, which exits at
+// once. Commands that must clean up first call signal.Stop on it and
+// handle the signals themselves.
+var interrupts chan os.Signal
 
 var rootCmd = &cobra.Command{
 	Use:   "codag",
@@ -24,22 +32,38 @@ var rootCmd = &cobra.Command{
 	},
 	SilenceUsage:  true,
 	SilenceErrors: true,
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := setupOutput(cmd); err != nil {
+			return err
+		}
+		// stderr, since stdout is the protocol stream for `mcp serve`
+		if from, err := config.MigrateLegacyHome(); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: could not move settings from %s: %s\n", from, err)
+		} else if from != "" {
+			fmt.Fprintf(os.Stderr, "Moved settings from %s to %s\n", from, config.CodagHome)
+		}
+		if err := useProfile(cmd); err != nil {
+			return err
+		}
 		config.LoadEnv()
+		httpclient.SetVersion(Version)
+		enableTracing(cmd)
 
 		// Background update check (non-blocking)
 		if cmd.Name() != "upgrade" {
+			updateCheckDone = make(chan struct{})
 			startUpdateCheck(updateCheckDone)
 		}
 
 		// SIGINT / SIGTERM handler
-		sigCh := make(chan os.Signal, 1)
-		signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
-		go func() {
-			<-sigCh
-			fmt.Println("\n\nSee ya!")
+		interrupts = make(chan os.Signal, 1)
+		signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+		go func(ch <-chan os.Signal) {
+			<-ch
+			fmt.Fprintln(textOut, "\n\nSee ya!")
 			os.Exit(0)
-		}()
+		}(interrupts)
+		return nil
 	},
 	PersistentPostRun: func(cmd *cobra.Command, args []string) {
 		if cmd.Name() != "upgrade" {
@@ -55,15 +79,70 @@ func Execute() error {
 
 func init() {
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
+	rootCmd.PersistentFlags().String("profile", "", "Use a named profile (or set CODAG_PROFILE)")
+	rootCmd.PersistentFlags().Bool("debug", false, "Log HTTP requests and responses to stderr (or set CODAG_DEBUG=1)")
+	rootCmd.PersistentFlags().String("har", "", "Record HTTP traffic to a HAR file (or set CODAG_HAR)")
+	rootCmd.PersistentFlags().StringP("output", "o", outputTable, "Output format: table, json or yaml (see: codag help output)")
 	rootCmd.AddCommand(loginCmd)
 	rootCmd.AddCommand(logoutCmd)
 	rootCmd.AddCommand(accountCmd)
+	rootCmd.AddCommand(authCmd)
+	rootCmd.AddCommand(configCmd)
 	rootCmd.AddCommand(initCmd)
 	rootCmd.AddCommand(indexCmd)
+	rootCmd.AddCommand(waitCmd)
 	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(repoCmd)
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(upgradeCmd)
 	rootCmd.AddCommand(mcpCmd)
+	rootCmd.AddCommand(profileCmd)
+	rootCmd.AddCommand(outputHelpCmd)
+}
+
+// useProfile switches config to the profile chosen by --profile,
+// CODAG_PROFILE or `codag profile use`. Profile management commands still
+// run when the chosen profile is missing, so it can be fixed.
+//
+// `mcp serve` reads CODAG_PROFILE from a project's editor config, which may
+// be shared with teammates who don't have that profile. It falls back to
+// the default profile rather than failing to start.
+func useProfile(cmd *cobra.Command) error {
+	flag, _ := cmd.Flags().GetString("profile")
+	name := config.SelectProfile(flag)
+	if err := config.UseProfile(name); err != nil {
+		if cmd.Parent() == profileCmd {
+			return nil
+		}
+		if cmd == mcpServeCmd && flag == "" {
+			fmt.Fprintf(os.Stderr, "warning: profile %q does not exist, using the default profile\n", name)
+			return config.UseProfile(config.DefaultProfile)
+		}
+		ui.Error(err.Error())
+		return silent(err)
+	}
+	return nil
+}
+
+// enableTracing turns on HTTP debug logging and HAR recording from the
+// --debug/--har flags or the CODAG_DEBUG/CODAG_HAR environment variables.
+// Tokens and Authorization headers are redacted in both.
+func enableTracing(cmd *cobra.Command) {
+	debug, _ := cmd.Flags().GetBool("debug")
+	if !debug {
+		debug, _ = config.ParseBool(os.Getenv("CODAG_DEBUG"))
+	}
+	if debug {
+		httpclient.EnableDebug(os.Stderr)
+	}
+
+	har, _ := cmd.Flags().GetString("har")
+	if har == "" {
+		har = os.Getenv("CODAG_HAR")
+	}
+	if har != "" {
+		httpclient.EnableHAR(har)
+	}
 }
 
 // addServerFlag adds the hidden --server flag and --dev shortcut to a command.
@@ -73,8 +152,72 @@ func addServerFlag(cmd *cobra.Command) {
 	cmd.Flags().Bool("dev", false, "Use local dev server (http://localhost:8000)")
 }
 
-// resolveServer returns the API base URL from --dev > --server flag > env > default.
+// addRepoFilterFlags adds the --owner, --org and --filter flags used by
+// commands that list repos.
+func addRepoFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().String("owner", "", "Only repos owned by this GitHub user or org")
+	cmd.Flags().String("org", "", "Only repos in this Codag org")
+	cmd.Flags().String("filter", "", "Only repos whose owner/name matches (substring or glob)")
+}
+
+// repoListOptions builds listing options from the repo filter flags.
+func repoListOptions(cmd *cobra.Command) api.ListReposOptions {
+	owner, _ := cmd.Flags().GetString("owner")
+	org, _ := cmd.Flags().GetString("org")
+	filter, _ := cmd.Flags().GetString("filter")
+	return api.ListReposOptions{Owner: owner, Org: org, Filter: filter}
+}
+
+// loadProject reads .codag.yml for dir, printing an error if it's invalid.
+func loadProject(dir string) (*project.Config, error) {
+	proj, err := project.Load(dir)
+	if err != nil {
+		ui.Error("Invalid project config: " + err.Error())
+		return proj, silent(err)
+	}
+	return proj, nil
+}
+
+// gitHosts returns the self-hosted git servers configured in .codag.yml
+// and the git-hosts setting, the setting winning.
+func gitHosts(proj *project.Config) remote.Hosts {
+	return proj.GitHosts().Merge(config.GetGitHosts())
+}
+
+// maxPRsFlag returns --max-prs, falling back to max_prs in .codag.yml.
+// Nil means the server default.
+func maxPRsFlag(cmd *cobra.Command, proj *project.Config) *int {
+	maxPRs, _ := cmd.Flags().GetInt("max-prs")
+	if !cmd.Flags().Changed("max-prs") && proj.MaxPRs > 0 {
+		maxPRs = proj.MaxPRs
+	}
+	if maxPRs <= 0 {
+		return nil
+	}
+	return &maxPRs
+}
+
+// authedClient returns an API client for the resolved server, or prints a
+// login hint and returns a silent error when not logged in.
+func authedClient(cmd *cobra.Command) (*api.Client, string, error) {
+	token, err := config.RequireAuth()
+	if err != nil {
+		ui.Error("Not logged in.")
+		fmt.Fprintln(os.Stderr, "  Run: codag login")
+		return nil, "", silent(err)
+	}
+	server := resolveServer(cmd)
+	return api.NewClient(server, token), server, nil
+}
+
+// resolveServer returns the API base URL from --dev > --server flag > env >
+// .codag.yml > default.
 func resolveServer(cmd *cobra.Command) string {
+	return resolveServerIn(cmd, ".")
+}
+
+// resolveServerIn is resolveServer using the project config for dir.
+func resolveServerIn(cmd *cobra.Command, dir string) string {
 	if dev, _ := cmd.Flags().GetBool("dev"); dev {
 		return "http://localhost:8000"
 	}
@@ -84,6 +227,8 @@ func resolveServer(cmd *cobra.Command) string {
 		server = s
 	} else if s := config.GetServerURL(); s != "" {
 		server = s
+	} else if proj, _ := project.Load(dir); proj.Server != "" {
+		server = proj.Server
 	} else {
 		server = api.DefaultServer
 	}
@@ -1,8 +1,13 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/codag-megalith/codag-cli/internal/api"
 	"github.com/codag-megalith/codag-cli/internal/config"
@@ -23,51 +28,167 @@ var statusCmd = &cobra.Command{
 
 		server := resolveServer(cmd)
 		client := api.NewClient(server, token)
+		if watch, _ := cmd.Flags().GetBool("watch"); watch {
+			return watchStatus(cmd, client)
+		}
 
-		repos, err := client.ListRepos()
+		// Render each page as it arrives so large orgs show output immediately
+		var onPage func([]repoResult)
+		if !machineOutput(cmd) {
+			printed := false
+			onPage = func(repos []repoResult) {
+				for _, r := range repos {
+					if !printed {
+						fmt.Fprintln(textOut)
+						printed = true
+					}
+					printRepoStatus(r)
+				}
+			}
+		}
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		result, err := fetchStatus(client, repoListOptions(cmd), timeout, onPage)
 		if err != nil {
 			return handleAPIError(err, server)
 		}
 
-		if len(repos) == 0 {
-			ui.Info("No repos registered. Run: codag init")
-			return nil
-		}
-
-		fmt.Println()
-		for _, repo := range repos {
-			name := fmt.Sprintf("%s/%s", repo.Owner, repo.Name)
-			indexed := "never"
-			if repo.LastIndexedAt != nil {
-				indexed = *repo.LastIndexedAt
-				if len(indexed) > 10 {
-					indexed = indexed[:10]
+		return render(cmd, result, func() {
+			if len(result.Repos) == 0 {
+				ui.Info("No repos registered. Run: codag init")
+				return
+			}
+			failed, timedOut := 0, false
+			for _, r := range result.Repos {
+				if r.StatsError != "" {
+					failed++
+					timedOut = timedOut || strings.HasPrefix(r.StatsError, "timed out")
 				}
 			}
+			if failed > 0 {
+				msg := fmt.Sprintf("Couldn't fetch stats for %d of %d repos.", failed, len(result.Repos))
+				if timedOut {
+					msg += " Try a longer --timeout."
+				}
+				ui.Warn(msg)
+			}
+		})
+	},
+}
+
+type statusResult struct {
+	Repos []repoResult `json:"repos" yaml:"repos"`
+}
+
+// statsWorkers bounds the stats requests in flight when the server has
+// no bulk stats endpoint.
+const statsWorkers = 8
+
+// fetchStatus lists the repos matching opts with their stats, passing
+// each page to onPage, if set, once its stats are in. Fetching stats is
+// bounded by timeout overall; repos whose stats couldn't be fetched carry
+// the reason in StatsError.
+func fetchStatus(client *api.Client, opts api.ListReposOptions, timeout time.Duration, onPage func([]repoResult)) (statusResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-			stats, err := client.GetStats(repo.ID)
-			if err != nil {
-				// Silent error — print what we have
-				fmt.Printf("  %s\n", ui.Bold.Render(name))
-				ui.Keyval("Last indexed", indexed)
-				fmt.Println()
-				continue
+	defaultRepo := config.GetDefaultRepo()
+	result := statusResult{Repos: []repoResult{}}
+	err := client.EachRepoPage(opts, func(repos []api.RepoResponse) error {
+		stats, errs := fetchStats(ctx, client, repos)
+		start := len(result.Repos)
+		for i, repo := range repos {
+			r := newRepoResult(repo, defaultRepo, stats[i])
+			if errs[i] != nil {
+				r.StatsError = statsErrorText(errs[i], timeout)
 			}
+			result.Repos = append(result.Repos, r)
+		}
+		if onPage != nil {
+			onPage(result.Repos[start:])
+		}
+		return nil
+	})
+	return result, err
+}
 
-			fmt.Printf("  %s\n", ui.Bold.Render(name))
-			ui.Keyval("Last indexed", indexed)
-			fmt.Printf("  PRs: %d | Files w/ signals: %d | Signals: %d (%d danger)\n",
-				stats.PRsIndexed, stats.FilesWithSignals, stats.TotalSignals, stats.DangerSignals)
-			if stats.Indexing {
-				fmt.Printf("  %s\n", ui.Yellow.Render("Status: indexing..."))
+// fetchStats gets stats for repos in one bulk request if the server
+// supports it, and otherwise with up to statsWorkers requests at a time.
+// Results are in the order of repos; each has either stats or an error.
+func fetchStats(ctx context.Context, client *api.Client, repos []api.RepoResponse) ([]*api.StatsResponse, []error) {
+	stats := make([]*api.StatsResponse, len(repos))
+	errs := make([]error, len(repos))
+	if len(repos) == 0 {
+		return stats, errs
+	}
+
+	ids := make([]int, len(repos))
+	for i, repo := range repos {
+		ids[i] = repo.ID
+	}
+	if bulk, err := client.GetStatsBulk(ctx, ids); err == nil {
+		for i, repo := range repos {
+			if st, ok := bulk[repo.ID]; ok {
+				stats[i] = &st
 			}
-			fmt.Println()
 		}
+	} else if ctx.Err() != nil {
+		for i := range repos {
+			errs[i] = ctx.Err()
+		}
+		return stats, errs
+	}
 
-		return nil
-	},
+	// Fetch whatever the bulk request didn't cover one by one
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(statsWorkers, len(repos)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				stats[i], errs[i] = client.GetStatsContext(ctx, repos[i].ID)
+			}
+		}()
+	}
+	for i := range repos {
+		if stats[i] == nil {
+			jobs <- i
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return stats, errs
+}
+
+// statsErrorText explains why a repo's stats are missing.
+func statsErrorText(err error, timeout time.Duration) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Sprintf("timed out after %s", timeout)
+	}
+	return err.Error()
 }
 
 func init() {
+	statusCmd.Flags().BoolP("watch", "w", false, "Keep refreshing: a live dashboard on a terminal, a line per repo otherwise")
+	statusCmd.Flags().Duration("interval", 5*time.Second, "Refresh interval for --watch")
+	statusCmd.Flags().Duration("timeout", 30*time.Second, "Give up on stats not fetched within this time")
+	addRepoFilterFlags(statusCmd)
 	addServerFlag(statusCmd)
 }
+
+// printRepoStatus prints the last-indexed date and signal stats for a
+// repo, or why its stats are missing.
+func printRepoStatus(r repoResult) {
+	fmt.Fprintf(textOut, "  %s\n", ui.Bold.Render(r.Repo))
+	ui.Keyval("Last indexed", lastIndexed(r.LastIndexedAt))
+	if r.Stats == nil {
+		fmt.Fprintf(textOut, "  %s\n\n", ui.Yellow.Render("Stats unavailable: "+r.StatsError))
+		return
+	}
+	fmt.Fprintf(textOut, "  PRs: %d | Files w/ signals: %d | Signals: %d (%d danger)\n",
+		r.Stats.PRsIndexed, r.Stats.FilesWithSignals, r.Stats.TotalSignals, r.Stats.DangerSignals)
+	if r.Stats.Indexing {
+		fmt.Fprintf(textOut, "  %s\n", ui.Yellow.Render("Status: indexing..."))
+	}
+	fmt.Fprintln(textOut)
+}
@@ -0,0 +1,457 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/codag-megalith/codag-cli/internal/api"
+	"github.com/codag-megalith/codag-cli/internal/ui"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
+)
+
+// watchRefreshes stops `status --watch` after that many refreshes when
+// positive. Variables so tests can end the loop and shorten the interval.
+var (
+	watchRefreshes   = 0
+	minWatchInterval = time.Second
+)
+
+// rateWindow is how far back the rate of change is measured.
+const rateWindow = time.Minute
+
+// Terminal control sequences for the dashboard.
+const (
+	enterAltScreen = "\x1b[?1049h\x1b[?25l" // alternate screen, cursor hidden
+	leaveAltScreen = "\x1b[?25h\x1b[?1049l"
+	clearScreen    = "\x1b[H\x1b[2J"
+)
+
+// watchStatus refreshes status every --interval until interrupted: as a
+// full-screen dashboard on a terminal, as lines of text when stdout is
+// redirected, or as one document per refresh with --output json|yaml.
+func watchStatus(cmd *cobra.Command, client *api.Client) error {
+	interval, _ := cmd.Flags().GetDuration("interval")
+	if interval < minWatchInterval {
+		ui.Error(fmt.Sprintf("--interval must be at least %s", minWatchInterval))
+		return silent(fmt.Errorf("interval too short"))
+	}
+	opts := repoListOptions(cmd)
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	refresh := func() (statusResult, error) {
+		return fetchStatus(client, opts, timeout, nil)
+	}
+
+	switch {
+	case machineOutput(cmd):
+		return watchPlain(refresh, interval, documentPrinter(cmd))
+	case stdoutIsTerminal():
+		return watchDashboard(refresh, interval)
+	default:
+		rates := newRateTracker(rateWindow)
+		return watchPlain(refresh, interval, func(result statusResult, at time.Time) error {
+			rates.add(at, result.Repos)
+			for _, r := range result.Repos {
+				fmt.Fprintln(textOut, statusLine(r, rates, at))
+			}
+			return nil
+		})
+	}
+}
+
+// watchPlain prints each refresh with print. Failed refreshes are
+// reported on stderr and retried at the next interval.
+func watchPlain(refresh func() (statusResult, error), interval time.Duration, print func(statusResult, time.Time) error) error {
+	for n := 1; ; n++ {
+		result, err := refresh()
+		at := time.Now()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s refresh failed: %v\n", at.Format(time.RFC3339), err)
+		} else if err := print(result, at); err != nil {
+			return err
+		}
+		if watchRefreshes > 0 && n >= watchRefreshes {
+			return nil
+		}
+		time.Sleep(interval)
+	}
+}
+
+// documentPrinter writes one status document per refresh: a line of
+// JSON each, or a YAML stream.
+func documentPrinter(cmd *cobra.Command) func(statusResult, time.Time) error {
+	if outputFormat(cmd) == outputYAML {
+		enc := yaml.NewEncoder(resultOut)
+		enc.SetIndent(2)
+		return func(result statusResult, _ time.Time) error {
+			return enc.Encode(result)
+		}
+	}
+	enc := json.NewEncoder(resultOut)
+	enc.SetEscapeHTML(false)
+	return func(result statusResult, _ time.Time) error {
+		return enc.Encode(result)
+	}
+}
+
+// statusLine describes a repo in one logfmt-style line. Rates appear from
+// the second refresh.
+func statusLine(r repoResult, rates *rateTracker, at time.Time) string {
+	line := fmt.Sprintf("time=%s repo=%s state=%s", at.UTC().Format(time.RFC3339), r.Repo, strings.ReplaceAll(repoState(r), " ", "_"))
+	if r.Stats != nil {
+		line += fmt.Sprintf(" prs=%d signals=%d danger=%d", r.Stats.PRsIndexed, r.Stats.TotalSignals, r.Stats.DangerSignals)
+	} else {
+		line += fmt.Sprintf(" error=%q", r.StatsError)
+	}
+	if prs, signals, ok := rates.perMinute(r.ID); ok {
+		line += fmt.Sprintf(" prs_per_min=%.1f signals_per_min=%.1f", prs, signals)
+	}
+	return line
+}
+
+// repoState summarises a repo for the watch views.
+func repoState(r repoResult) string {
+	switch {
+	case r.Stats == nil:
+		return "failed"
+	case r.Stats.Indexing:
+		return "indexing"
+	case r.LastIndexedAt == nil:
+		return "not indexed"
+	}
+	return "indexed"
+}
+
+// refreshed is the outcome of one dashboard refresh.
+type refreshed struct {
+	result statusResult
+	err    error
+	at     time.Time
+}
+
+// watchDashboard runs the full-screen dashboard until q, Ctrl-C or a
+// signal. Refreshes run in the background so keys and resizes are
+// handled while stats load.
+func watchDashboard(refresh func() (statusResult, error), interval time.Duration) error {
+	// Restore the terminal before exiting, rather than leaving that to the
+	// default handler
+	signal.Stop(interrupts)
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	resize := make(chan os.Signal, 1)
+	notifyResize(resize)
+	defer signal.Stop(resize)
+
+	// In raw mode Ctrl-C arrives as a key rather than a signal
+	quit := make(chan struct{})
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		if state, err := term.MakeRaw(fd); err == nil {
+			defer term.Restore(fd, state)
+		}
+		go readQuitKeys(os.Stdin, quit)
+	}
+
+	out := textOut
+	fmt.Fprint(out, enterAltScreen)
+	defer fmt.Fprint(out, leaveAltScreen)
+
+	rates := newRateTracker(rateWindow)
+	updates := make(chan refreshed, 1)
+	start := func() {
+		go func() {
+			result, err := refresh()
+			updates <- refreshed{result: result, err: err, at: time.Now()}
+		}()
+	}
+	var last refreshed
+	loading := true
+	draw := func() {
+		width, height := terminalSize()
+		d := dashboard{last: last, loading: loading, rates: rates, interval: interval}
+		fmt.Fprint(out, clearScreen+d.frame(width, height))
+	}
+
+	start()
+	draw()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-quit:
+			return nil
+		case <-sigs:
+			return nil
+		case <-resize:
+			draw()
+		case <-ticker.C:
+			if !loading {
+				loading = true
+				start()
+				draw()
+			}
+		case u := <-updates:
+			loading = false
+			if u.err == nil {
+				rates.add(u.at, u.result.Repos)
+				last = u
+			} else {
+				// Keep showing the last good results
+				last.err, last.at = u.err, u.at
+			}
+			draw()
+		}
+	}
+}
+
+// readQuitKeys closes quit when q, Ctrl-C or Ctrl-D is pressed.
+func readQuitKeys(r io.Reader, quit chan<- struct{}) {
+	buf := make([]byte, 16)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			return
+		}
+		for _, b := range buf[:n] {
+			switch b {
+			case 'q', 'Q', 3, 4:
+				close(quit)
+				return
+			}
+		}
+	}
+}
+
+// terminalSize returns stdout's size, or 80x24 if it's unknown.
+func terminalSize() (int, int) {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		return 80, 24
+	}
+	return width, height
+}
+
+// dashboard is the state drawn by one dashboard frame.
+type dashboard struct {
+	last     refreshed
+	loading  bool
+	rates    *rateTracker
+	interval time.Duration
+}
+
+// Dashboard columns after the repo name, dropped right to left when the
+// terminal is too narrow.
+var dashboardColumns = []struct {
+	title string
+	width int
+	left  bool // numbers are right-aligned
+}{
+	{"STATE", 11, true},
+	{"PRS", 7, false},
+	{"SIGNALS", 8, false},
+	{"DANGER", 7, false},
+	{"PRS/MIN", 8, false},
+	{"SIG/MIN", 8, false},
+}
+
+// minRepoWidth is the narrowest the repo column gets before other
+// columns are dropped.
+const minRepoWidth = 16
+
+// frame lays out the dashboard for a width x height terminal. Lines end
+// in \r\n since the terminal is in raw mode.
+func (d dashboard) frame(width, height int) string {
+	repos := d.last.result.Repos
+	var lines []string
+
+	header := ui.Bold.Render("codag status")
+	info := fmt.Sprintf(" · %d repos · every %s · q to quit", len(repos), d.interval)
+	if !d.last.at.IsZero() {
+		info = fmt.Sprintf(" · %d repos · updated %s · every %s · q to quit", len(repos), d.last.at.Format("15:04:05"), d.interval)
+	}
+	lines = append(lines, header+ui.Dim.Render(truncate(info, width-lipgloss.Width(header))))
+	switch {
+	case d.last.err != nil:
+		lines = append(lines, ui.Yellow.Render(truncate("! Refresh failed: "+d.last.err.Error(), width)))
+	case d.loading:
+		lines = append(lines, ui.Dim.Render("Refreshing..."))
+	default:
+		lines = append(lines, "")
+	}
+
+	// Fit the columns: drop from the right until the repo name has room
+	columns := len(dashboardColumns)
+	fixed := func() int {
+		w := 0
+		for _, c := range dashboardColumns[:columns] {
+			w += c.width + 1
+		}
+		return w
+	}
+	for columns > 1 && width-fixed() < minRepoWidth {
+		columns--
+	}
+	repoWidth := max(width-fixed(), minRepoWidth)
+
+	row := func(name string, cells []string) string {
+		var b strings.Builder
+		b.WriteString(pad(truncate(name, repoWidth), repoWidth))
+		for i, c := range dashboardColumns[:columns] {
+			if c.left {
+				b.WriteString(" " + pad(cells[i], c.width))
+			} else {
+				b.WriteString(" " + padLeft(cells[i], c.width))
+			}
+		}
+		return b.String()
+	}
+	titles := make([]string, len(dashboardColumns))
+	for i, c := range dashboardColumns {
+		titles[i] = c.title
+	}
+	lines = append(lines, ui.Bold.Render(row("REPO", titles)))
+
+	// Leave room for the totals and a "more" line
+	room := max(height-len(lines)-2, 0)
+	var total statsResult
+	indexing := 0
+	for i, r := range repos {
+		if r.Stats != nil {
+			total.PRsIndexed += r.Stats.PRsIndexed
+			total.TotalSignals += r.Stats.TotalSignals
+			total.DangerSignals += r.Stats.DangerSignals
+			if r.Stats.Indexing {
+				indexing++
+			}
+		}
+		if i >= room {
+			continue
+		}
+		lines = append(lines, d.repoRow(r, row))
+	}
+	if len(repos) > room {
+		lines = append(lines, ui.Dim.Render(fmt.Sprintf("… %d more; enlarge the window or use --filter", len(repos)-room)))
+	}
+	if len(repos) == 0 && !d.loading && d.last.err == nil {
+		lines = append(lines, ui.Dim.Render("No repos registered. Run: codag init"))
+	}
+	lines = append(lines, ui.Bold.Render(row("TOTAL", []string{
+		fmt.Sprintf("%d active", indexing),
+		fmt.Sprint(total.PRsIndexed),
+		fmt.Sprint(total.TotalSignals),
+		fmt.Sprint(total.DangerSignals),
+		"", "",
+	})))
+
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	return strings.Join(lines, "\r\n")
+}
+
+// repoRow renders one repo, coloured by state. Styles are applied to
+// whole cells after padding so ANSI codes don't upset the alignment.
+func (d dashboard) repoRow(r repoResult, row func(string, []string) string) string {
+	cells := []string{repoState(r), "-", "-", "-", "-", "-"}
+	if r.Stats != nil {
+		cells[1] = fmt.Sprint(r.Stats.PRsIndexed)
+		cells[2] = fmt.Sprint(r.Stats.TotalSignals)
+		cells[3] = fmt.Sprint(r.Stats.DangerSignals)
+	}
+	if prs, signals, ok := d.rates.perMinute(r.ID); ok {
+		cells[4] = formatRate(prs)
+		cells[5] = formatRate(signals)
+	}
+	line := row(r.Repo, cells)
+	switch repoState(r) {
+	case "indexing":
+		return ui.Yellow.Render(line)
+	case "failed":
+		return ui.Red.Render(line)
+	case "not indexed":
+		return ui.Dim.Render(line)
+	}
+	return line
+}
+
+func formatRate(perMinute float64) string {
+	if perMinute == 0 {
+		return "0"
+	}
+	return fmt.Sprintf("%+.1f", perMinute)
+}
+
+// truncate shortens s to width columns, marking the cut with "…".
+func truncate(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	r := []rune(s)
+	if len(r) <= width {
+		return s
+	}
+	return string(r[:width-1]) + "…"
+}
+
+func pad(s string, width int) string {
+	return s + strings.Repeat(" ", max(width-len([]rune(s)), 0))
+}
+
+func padLeft(s string, width int) string {
+	return strings.Repeat(" ", max(width-len([]rune(s)), 0)) + s
+}
+
+// rateTracker keeps each repo's recent counts to report how fast they
+// change.
+type rateTracker struct {
+	window  time.Duration
+	samples map[int][]rateSample
+}
+
+type rateSample struct {
+	at           time.Time
+	prs, signals int
+}
+
+func newRateTracker(window time.Duration) *rateTracker {
+	return &rateTracker{window: window, samples: map[int][]rateSample{}}
+}
+
+// add records a refresh. Samples older than the window are dropped,
+// except the newest of them, which anchors the rate.
+func (t *rateTracker) add(at time.Time, repos []repoResult) {
+	for _, r := range repos {
+		if r.Stats == nil {
+			continue
+		}
+		s := append(t.samples[r.ID], rateSample{at: at, prs: r.Stats.PRsIndexed, signals: r.Stats.TotalSignals})
+		for len(s) > 2 && at.Sub(s[1].at) >= t.window {
+			s = s[1:]
+		}
+		t.samples[r.ID] = s
+	}
+}
+
+// perMinute returns the PRs and signals a repo gained per minute over the
+// window. ok is false until it has been seen twice.
+func (t *rateTracker) perMinute(id int) (prs, signals float64, ok bool) {
+	s := t.samples[id]
+	if len(s) < 2 {
+		return 0, 0, false
+	}
+	first, last := s[0], s[len(s)-1]
+	minutes := last.at.Sub(first.at).Minutes()
+	if minutes <= 0 {
+		return 0, 0, false
+	}
+	return float64(last.prs-first.prs) / minutes, float64(last.signals-first.signals) / minutes, true
+}
@@ -5,12 +5,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/codag-megalith/codag-cli/internal/config"
 	"github.com/codag-megalith/codag-cli/internal/ui"
+	"github.com/codag-megalith/codag-cli/internal/version"
 )
 
 const checkInterval = 24 * time.Hour
@@ -23,7 +23,7 @@ type updateCache struct {
 var updateAvailable string // set by background check, read after command runs
 
 func cacheFilePath() string {
-	return filepath.Join(config.CodagHome, ".update-check")
+	return filepath.Join(config.CacheDir, ".update-check")
 }
 
 // startUpdateCheck runs a non-blocking background version check.
@@ -41,7 +41,7 @@ func startUpdateCheck(done chan struct{}) {
 		if err == nil && time.Since(cache.CheckedAt) < checkInterval {
 			current := strings.TrimPrefix(Version, "v")
 			latest := strings.TrimPrefix(cache.LatestVersion, "v")
-			if latest != "" && isNewer(latest, current) {
+			if latest != "" && version.IsNewer(latest, current) {
 				updateAvailable = latest
 			}
 			return
@@ -60,7 +60,7 @@ func startUpdateCheck(done chan struct{}) {
 		})
 
 		current := strings.TrimPrefix(Version, "v")
-		if isNewer(latest, current) {
+		if version.IsNewer(latest, current) {
 			updateAvailable = latest
 		}
 	}()
@@ -70,7 +70,7 @@ func printUpdateNotice() {
 	if updateAvailable == "" {
 		return
 	}
-	fmt.Println()
+	fmt.Fprintln(textOut)
 	ui.Warn(fmt.Sprintf("A new version of codag is available: %s → %s", Version, updateAvailable))
 	ui.Info("Run `codag upgrade` to update.")
 }
@@ -92,37 +92,6 @@ func writeCache(c *updateCache) {
 	if err != nil {
 		return
 	}
-	os.MkdirAll(config.CodagHome, 0700)
+	os.MkdirAll(config.CacheDir, 0700)
 	os.WriteFile(cacheFilePath(), data, 0600)
 }
-
-// isNewer returns true if a > b using semantic versioning comparison.
-func isNewer(a, b string) bool {
-	aParts := strings.Split(a, ".")
-	bParts := strings.Split(b, ".")
-
-	// Pad to same length
-	maxLen := len(aParts)
-	if len(bParts) > maxLen {
-		maxLen = len(bParts)
-	}
-	for len(aParts) < maxLen {
-		aParts = append(aParts, "0")
-	}
-	for len(bParts) < maxLen {
-		bParts = append(bParts, "0")
-	}
-
-	// Compare each part
-	for i := 0; i < maxLen; i++ {
-		aNum, _ := strconv.Atoi(aParts[i])
-		bNum, _ := strconv.Atoi(bParts[i])
-		if aNum > bNum {
-			return true
-		}
-		if aNum < bNum {
-			return false
-		}
-	}
-	return false
-}
@@ -15,6 +15,7 @@ import (
 	"runtime"
 	"strings"
 
+	"github.com/codag-megalith/codag-cli/internal/httpclient"
 	"github.com/codag-megalith/codag-cli/internal/ui"
 	"github.com/spf13/cobra"
 )
@@ -137,7 +138,7 @@ func init() {
 
 func fetchLatestRelease() (*ghRelease, error) {
 	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/releases/latest", repoOwner, repoName)
-	resp, err := http.Get(url)
+	resp, err := httpclient.New(0).Get(url)
 	if err != nil {
 		return nil, err
 	}
@@ -163,7 +164,7 @@ func expectedAssetName() string {
 }
 
 func downloadFile(url, dest string) error {
-	resp, err := http.Get(url)
+	resp, err := httpclient.New(0).Get(url)
 	if err != nil {
 		return err
 	}
@@ -304,7 +305,7 @@ func verifyChecksum(release *ghRelease, assetName, archivePath string) error {
 	}
 
 	// Download checksums.txt
-	resp, err := http.Get(checksumAsset.BrowserDownloadURL)
+	resp, err := httpclient.New(0).Get(checksumAsset.BrowserDownloadURL)
 	if err != nil {
 		return fmt.Errorf("downloading checksums: %w", err)
 	}
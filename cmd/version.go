@@ -12,14 +12,23 @@ var (
 	BuildDate = "unknown"
 )
 
+type versionResult struct {
+	Version   string `json:"version" yaml:"version"`
+	Commit    string `json:"commit" yaml:"commit"`
+	BuildDate string `json:"build_date" yaml:"build_date"`
+}
+
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Print version information",
-	Run: func(cmd *cobra.Command, args []string) {
-		commit := Commit
-		if len(commit) > 7 {
-			commit = commit[:7]
-		}
-		fmt.Printf("codag %s (%s) built %s\n", Version, commit, BuildDate)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		result := versionResult{Version: Version, Commit: Commit, BuildDate: BuildDate}
+		return render(cmd, result, func() {
+			commit := result.Commit
+			if len(commit) > 7 {
+				commit = commit[:7]
+			}
+			fmt.Fprintf(textOut, "codag %s (%s) built %s\n", result.Version, commit, result.BuildDate)
+		})
 	},
 }
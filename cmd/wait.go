@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/codag-megalith/codag-cli/internal/config"
+	"github.com/codag-megalith/codag-cli/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var waitCmd = &cobra.Command{
+	Use:   "wait [repo]",
+	Short: "Wait for a repo's indexing to finish",
+	Long: `Follow indexing already in progress, such as one started with --no-wait
+or from another terminal, until it finishes. If the repo isn't being
+indexed, its stats are printed straight away. Exits non-zero if indexing
+is cancelled or doesn't finish within --timeout.
+
+The repo is chosen as for 'codag index'.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, server, err := authedClient(cmd)
+		if err != nil {
+			return err
+		}
+		repo, err := indexTarget(cmd, client, server, args)
+		if err != nil {
+			return err
+		}
+
+		stats, err := client.GetStats(repo.ID)
+		if err != nil {
+			return handleAPIError(err, server)
+		}
+		result := waitResult{
+			Repo:        newRepoResult(*repo, config.GetDefaultRepo(), nil),
+			WasIndexing: stats.Indexing,
+		}
+		if !stats.Indexing {
+			if stats.Cancelled {
+				ui.Error(fmt.Sprintf("%s isn't being indexed; its last run was cancelled.", repoPath(*repo)))
+				return silent(errors.New("indexing cancelled"))
+			}
+			result.Stats = newStatsResult(stats)
+			return render(cmd, result, func() {
+				ui.Info(fmt.Sprintf("%s isn't being indexed.", repoPath(*repo)))
+				printIndexStats(stats)
+			})
+		}
+
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		stats, err = pollIndexing(client, repo.ID, timeout)
+		if errors.Is(err, errIndexingTimedOut) {
+			ui.Error(fmt.Sprintf("Indexing didn't finish within %s.", timeout))
+			fmt.Fprintf(os.Stderr, "  It carries on; wait longer with --timeout, or stop it with: codag index cancel %s\n", repoPath(*repo))
+			return silent(err)
+		}
+		if err != nil {
+			return err
+		}
+		result.Stats = newStatsResult(stats)
+		return render(cmd, result, nil)
+	},
+}
+
+// waitResult is what wait reports once indexing has finished.
+type waitResult struct {
+	Repo        repoResult   `json:"repo" yaml:"repo"`
+	WasIndexing bool         `json:"was_indexing" yaml:"was_indexing"`
+	Stats       *statsResult `json:"stats" yaml:"stats"`
+}
+
+func init() {
+	waitCmd.Flags().Duration("timeout", pollTimeout, "Give up if indexing hasn't finished within this time")
+	addIndexTargetFlags(waitCmd)
+	addServerFlag(waitCmd)
+}
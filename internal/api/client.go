@@ -2,23 +2,42 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/codag-megalith/codag-cli/internal/config"
+	"github.com/codag-megalith/codag-cli/internal/httpclient"
+	"github.com/codag-megalith/codag-cli/internal/jwt"
+	"github.com/codag-megalith/codag-cli/internal/remote"
 )
 
 const DefaultServer = "https://api.codag.ai"
 
+// RefreshLeeway is how long before expiry an access token is refreshed.
+const RefreshLeeway = 2 * time.Minute
+
+// Client calls the Codag API. It is safe for concurrent use once
+// requests are running; requests that hit an expired token share one
+// refresh.
 type Client struct {
 	BaseURL      string
 	Token        string
 	RefreshToken string
 	HTTPClient   *http.Client
+
+	mu          sync.Mutex // guards the tokens and noBulkStats
+	noBulkStats bool       // the server has no bulk stats endpoint
 }
 
 type RepoResponse struct {
@@ -26,6 +45,9 @@ type RepoResponse struct {
 	Name          string  `json:"name"`
 	Owner         string  `json:"owner"`
 	GithubURL     string  `json:"github_url"`
+	Provider      string  `json:"provider"`
+	Host          string  `json:"host"`
+	URL           string  `json:"url"`
 	LastIndexedAt *string `json:"last_indexed_at"`
 }
 
@@ -42,6 +64,7 @@ type StatsResponse struct {
 	TotalSignals     int  `json:"total_signals"`
 	DangerSignals    int  `json:"danger_signals"`
 	Indexing         bool `json:"indexing"`
+	Cancelled        bool `json:"cancelled"` // the last run was cancelled
 }
 
 type APIError struct {
@@ -58,14 +81,13 @@ func NewClient(baseURL, token string) *Client {
 		BaseURL:      baseURL,
 		Token:        token,
 		RefreshToken: config.GetRefreshToken(),
-		HTTPClient: &http.Client{
-			Timeout: 600 * time.Second,
-		},
+		HTTPClient:   httpclient.NewForServer(baseURL, 600*time.Second),
 	}
 }
 
-func (c *Client) RegisterRepo(githubURL string) (*RepoResponse, error) {
-	body := map[string]string{"github_url": githubURL}
+// RegisterRepo registers a repo on any supported git host.
+func (c *Client) RegisterRepo(repo remote.Repo) (*RepoResponse, error) {
+	body := RepoRef(repo)
 	data, err := c.do("POST", "/api/repos", body)
 	if err != nil {
 		return nil, err
@@ -77,10 +99,121 @@ func (c *Client) RegisterRepo(githubURL string) (*RepoResponse, error) {
 	return &resp, nil
 }
 
-func (c *Client) TriggerBackfill(repoID int, maxPRs *int) (*BackfillResponse, error) {
+// GetRepo fetches a registered repo by ID.
+func (c *Client) GetRepo(id int) (*RepoResponse, error) {
+	data, err := c.do("GET", fmt.Sprintf("/api/repos/%d", id), nil)
+	if err != nil {
+		return nil, err
+	}
+	var resp RepoResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("parsing response: %w", err)
+	}
+	return &resp, nil
+}
+
+// DeleteRepo unregisters a repo and deletes its indexed data.
+func (c *Client) DeleteRepo(id int) error {
+	_, err := c.do("DELETE", fmt.Sprintf("/api/repos/%d", id), nil)
+	return err
+}
+
+// FindRepos returns the registered repos whose path is exactly
+// "owner/name", compared case-insensitively. There is more than one only
+// when repos on different hosts share a path.
+func (c *Client) FindRepos(repoPath string) ([]RepoResponse, error) {
+	repos, err := c.ListRepos(ListReposOptions{Filter: repoPath})
+	if err != nil {
+		return nil, err
+	}
+	var found []RepoResponse
+	for _, r := range repos {
+		if strings.EqualFold(r.Owner+"/"+r.Name, repoPath) {
+			found = append(found, r)
+		}
+	}
+	return found, nil
+}
+
+// ResolveRepo looks up a registered repo. An unregistered repo is an
+// APIError with status 404.
+func (c *Client) ResolveRepo(repo remote.Repo) (*RepoResponse, error) {
+	q := url.Values{}
+	for k, v := range RepoRef(repo) {
+		q.Set(k, v)
+	}
+	data, err := c.do("GET", "/api/repos/resolve?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	var resp RepoResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("parsing response: %w", err)
+	}
+	return &resp, nil
+}
+
+// RepoRef identifies a repo to the API by provider, host and path.
+// github_url is also sent for GitHub repos, for servers that predate
+// other providers.
+func RepoRef(repo remote.Repo) map[string]string {
+	ref := map[string]string{
+		"provider": string(repo.Provider),
+		"host":     repo.Host,
+		"path":     repo.Path(),
+		"url":      repo.URL(),
+	}
+	if repo.Provider == remote.GitHub {
+		ref["github_url"] = repo.URL()
+	}
+	return ref
+}
+
+// BackfillOptions selects what a backfill indexes. The zero value is a
+// full re-index, which deletes the repo's existing signals first.
+type BackfillOptions struct {
+	MaxPRs *int
+
+	// Incremental merges new signals into the existing ones instead of
+	// starting over. Since, Until and PRs narrow it; left unset, the
+	// server picks up everything after its last run.
+	Incremental bool
+	Since       time.Time // PRs merged at or after
+	Until       time.Time // PRs merged before
+	PRs         *PRRange
+}
+
+// PRRange is an inclusive range of PR numbers. A zero To is open-ended.
+type PRRange struct {
+	From int
+	To   int
+}
+
+func (r PRRange) String() string {
+	if r.To == 0 {
+		return fmt.Sprintf("#%d onwards", r.From)
+	}
+	if r.From == r.To {
+		return fmt.Sprintf("#%d", r.From)
+	}
+	return fmt.Sprintf("#%d-%d", r.From, r.To)
+}
+
+// ErrIncrementalUnsupported is returned by TriggerBackfill for an
+// incremental backfill when the server predates them. It has not
+// indexed anything; only a full re-index is available.
+var ErrIncrementalUnsupported = errors.New("server can't index incrementally")
+
+// TriggerBackfill starts indexing a repo. Incremental backfills go to an
+// endpoint of their own, so a server that doesn't know them rejects the
+// request rather than running a full re-index in their place.
+func (c *Client) TriggerBackfill(repoID int, opts BackfillOptions) (*BackfillResponse, error) {
+	if opts.Incremental {
+		return c.triggerIncrementalBackfill(repoID, opts)
+	}
 	path := fmt.Sprintf("/api/repos/%d/backfill", repoID)
-	if maxPRs != nil {
-		path += fmt.Sprintf("?max_prs=%d", *maxPRs)
+	if opts.MaxPRs != nil {
+		path += fmt.Sprintf("?max_prs=%d", *opts.MaxPRs)
 	}
 	data, err := c.do("POST", path, nil)
 	if err != nil {
@@ -93,16 +226,225 @@ func (c *Client) TriggerBackfill(repoID int, maxPRs *int) (*BackfillResponse, er
 	return &resp, nil
 }
 
-func (c *Client) ListRepos() ([]RepoResponse, error) {
-	data, err := c.do("GET", "/api/repos", nil)
+func (c *Client) triggerIncrementalBackfill(repoID int, opts BackfillOptions) (*BackfillResponse, error) {
+	body := map[string]interface{}{}
+	if opts.MaxPRs != nil {
+		body["max_prs"] = *opts.MaxPRs
+	}
+	if !opts.Since.IsZero() {
+		body["since"] = opts.Since.Format(time.RFC3339)
+	}
+	if !opts.Until.IsZero() {
+		body["until"] = opts.Until.Format(time.RFC3339)
+	}
+	if opts.PRs != nil {
+		body["prs_from"] = opts.PRs.From
+		if opts.PRs.To != 0 {
+			body["prs_to"] = opts.PRs.To
+		}
+	}
+
+	path := fmt.Sprintf("/api/repos/%d/backfill/incremental", repoID)
+	data, err := c.do("POST", path, body)
+	if isMissingRoute(err) {
+		return nil, ErrIncrementalUnsupported
+	}
 	if err != nil {
 		return nil, err
 	}
-	var resp []RepoResponse
+	var resp BackfillResponse
 	if err := json.Unmarshal(data, &resp); err != nil {
 		return nil, fmt.Errorf("parsing response: %w", err)
 	}
-	return resp, nil
+	return &resp, nil
+}
+
+// ErrCancelUnsupported is returned by CancelBackfill when the server
+// predates it.
+var ErrCancelUnsupported = errors.New("server can't cancel indexing")
+
+// CancelBackfill stops indexing a repo. The status is "cancelled", or
+// "not_running" if there was nothing to stop.
+func (c *Client) CancelBackfill(repoID int) (*BackfillResponse, error) {
+	data, err := c.do("POST", fmt.Sprintf("/api/repos/%d/backfill/cancel", repoID), nil)
+	if isMissingRoute(err) {
+		return nil, ErrCancelUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+	var resp BackfillResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("parsing response: %w", err)
+	}
+	return &resp, nil
+}
+
+// isMissingRoute reports whether err means the server has no such
+// endpoint. That is a bare 404, unlike a missing repo, or a 405.
+func isMissingRoute(err error) bool {
+	apiErr, ok := err.(*APIError)
+	return ok && (apiErr.StatusCode == 405 || apiErr.StatusCode == 404 && apiErr.Detail == "Not Found")
+}
+
+// ListReposOptions narrows a repo listing. Empty fields are not sent.
+type ListReposOptions struct {
+	Owner    string // GitHub owner (user or org login)
+	Org      string // Codag org slug
+	Filter   string // substring or glob matched against "owner/name"
+	Sort     string // server-side sort key, e.g. "-created_at"
+	PageSize int
+}
+
+// RepoPage is one page of a repo listing.
+type RepoPage struct {
+	Repos      []RepoResponse `json:"repos"`
+	NextCursor string         `json:"next_cursor"`
+
+	// legacy is set when the server returned a bare JSON array, i.e. it
+	// does not paginate and ignores sort and limit parameters.
+	legacy bool
+}
+
+// ErrOrgFilterUnsupported is returned when listing repos by org on a
+// server that predates filtering, rather than listing every org's repos.
+var ErrOrgFilterUnsupported = errors.New("server can't filter repos by org")
+
+// ListReposPage fetches a single page of repos starting at cursor.
+// Servers that predate pagination return everything as one page.
+func (c *Client) ListReposPage(opts ListReposOptions, cursor string) (*RepoPage, error) {
+	q := url.Values{}
+	if opts.Owner != "" {
+		q.Set("owner", opts.Owner)
+	}
+	if opts.Org != "" {
+		q.Set("org", opts.Org)
+	}
+	if opts.Filter != "" {
+		q.Set("q", opts.Filter)
+	}
+	if opts.Sort != "" {
+		q.Set("sort", opts.Sort)
+	}
+	if opts.PageSize > 0 {
+		q.Set("limit", strconv.Itoa(opts.PageSize))
+	}
+	if cursor != "" {
+		q.Set("cursor", cursor)
+	}
+
+	endpoint := "/api/repos"
+	if len(q) > 0 {
+		endpoint += "?" + q.Encode()
+	}
+	data, err := c.do("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var page RepoPage
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(data, &page.Repos); err != nil {
+			return nil, fmt.Errorf("parsing response: %w", err)
+		}
+		page.legacy = true
+	} else if err := json.Unmarshal(data, &page); err != nil {
+		return nil, fmt.Errorf("parsing response: %w", err)
+	}
+
+	// Older servers ignore the filter parameters, so apply them here too.
+	// Repos don't say which org they belong to, so that one can't be.
+	if page.legacy && opts.Org != "" {
+		return nil, ErrOrgFilterUnsupported
+	}
+	page.Repos = filterRepos(page.Repos, opts)
+	return &page, nil
+}
+
+// EachRepoPage calls fn for every page of repos as it arrives.
+// Returning an error from fn stops the iteration.
+func (c *Client) EachRepoPage(opts ListReposOptions, fn func([]RepoResponse) error) error {
+	cursor := ""
+	for {
+		page, err := c.ListReposPage(opts, cursor)
+		if err != nil {
+			return err
+		}
+		if err := fn(page.Repos); err != nil {
+			return err
+		}
+		if page.NextCursor == "" || page.NextCursor == cursor {
+			return nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// ListRepos returns every repo matching opts, following pagination.
+func (c *Client) ListRepos(opts ListReposOptions) ([]RepoResponse, error) {
+	var all []RepoResponse
+	err := c.EachRepoPage(opts, func(repos []RepoResponse) error {
+		all = append(all, repos...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+// MostRecentRepo returns the most recently registered repo matching opts,
+// or nil if there are none. It asks the server for a single repo sorted
+// newest-first instead of paging through the whole listing.
+func (c *Client) MostRecentRepo(opts ListReposOptions) (*RepoResponse, error) {
+	opts.Sort = "-created_at"
+	opts.PageSize = 1
+	page, err := c.ListReposPage(opts, "")
+	if err != nil {
+		return nil, err
+	}
+	if len(page.Repos) == 0 {
+		return nil, nil
+	}
+	// Unpaginated servers return repos oldest-first.
+	if page.legacy {
+		return &page.Repos[len(page.Repos)-1], nil
+	}
+	return &page.Repos[0], nil
+}
+
+// filterRepos applies owner and name filters locally.
+func filterRepos(repos []RepoResponse, opts ListReposOptions) []RepoResponse {
+	if opts.Owner == "" && opts.Filter == "" {
+		return repos
+	}
+	filtered := repos[:0]
+	for _, r := range repos {
+		if opts.Owner != "" && !strings.EqualFold(r.Owner, opts.Owner) {
+			continue
+		}
+		if opts.Filter != "" && !MatchRepo(r, opts.Filter) {
+			continue
+		}
+		filtered = append(filtered, r)
+	}
+	return filtered
+}
+
+// MatchRepo reports whether a repo matches a filter. Filters containing
+// glob metacharacters are matched against "owner/name" with path.Match;
+// anything else is a case-insensitive substring match.
+func MatchRepo(r RepoResponse, filter string) bool {
+	full := strings.ToLower(r.Owner + "/" + r.Name)
+	filter = strings.ToLower(filter)
+	if strings.ContainsAny(filter, "*?[") {
+		if ok, _ := path.Match(filter, full); ok {
+			return true
+		}
+		ok, _ := path.Match(filter, strings.ToLower(r.Name))
+		return ok
+	}
+	return strings.Contains(full, filter)
 }
 
 type WebhookResponse struct {
@@ -125,8 +467,13 @@ func (c *Client) SetupWebhook(repoID int) (*WebhookResponse, error) {
 }
 
 func (c *Client) GetStats(repoID int) (*StatsResponse, error) {
+	return c.GetStatsContext(context.Background(), repoID)
+}
+
+// GetStatsContext is GetStats with a context that can cancel the request.
+func (c *Client) GetStatsContext(ctx context.Context, repoID int) (*StatsResponse, error) {
 	path := fmt.Sprintf("/api/stats?repo=%d", repoID)
-	data, err := c.do("GET", path, nil)
+	data, err := c.doContext(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -137,6 +484,43 @@ func (c *Client) GetStats(repoID int) (*StatsResponse, error) {
 	return &resp, nil
 }
 
+// ErrBulkStatsUnsupported is returned by GetStatsBulk when the server
+// predates the bulk endpoint. Use GetStats for each repo instead.
+var ErrBulkStatsUnsupported = errors.New("server has no bulk stats endpoint")
+
+// GetStatsBulk fetches stats for many repos in one request. Repos the
+// server didn't report on are missing from the map.
+func (c *Client) GetStatsBulk(ctx context.Context, repoIDs []int) (map[int]StatsResponse, error) {
+	c.mu.Lock()
+	unsupported := c.noBulkStats
+	c.mu.Unlock()
+	if unsupported {
+		return nil, ErrBulkStatsUnsupported
+	}
+
+	data, err := c.doContext(ctx, "POST", "/api/stats/bulk", map[string][]int{"repo_ids": repoIDs})
+	if apiErr, ok := err.(*APIError); ok && (apiErr.StatusCode == 404 || apiErr.StatusCode == 405) {
+		c.mu.Lock()
+		c.noBulkStats = true
+		c.mu.Unlock()
+		return nil, ErrBulkStatsUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Stats []StatsResponse `json:"stats"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("parsing response: %w", err)
+	}
+	stats := make(map[int]StatsResponse, len(resp.Stats))
+	for _, st := range resp.Stats {
+		stats[st.RepoID] = st
+	}
+	return stats, nil
+}
+
 type MeResponse struct {
 	User struct {
 		GithubLogin string `json:"github_login"`
@@ -174,15 +558,28 @@ func (c *Client) GetMe() (*MeResponse, error) {
 }
 
 func (c *Client) do(method, path string, body interface{}) ([]byte, error) {
-	data, statusCode, err := c.doRaw(method, path, body)
+	return c.doContext(context.Background(), method, path, body)
+}
+
+func (c *Client) doContext(ctx context.Context, method, path string, body interface{}) ([]byte, error) {
+	token, refreshToken := c.tokens()
+
+	// Refresh ahead of expiry instead of waiting for a 401
+	if refreshToken != "" && jwt.ExpiresWithin(token, RefreshLeeway, time.Now()) {
+		c.refreshFrom(token)
+		token, _ = c.tokens()
+	}
+
+	data, statusCode, err := c.doRaw(ctx, method, path, token, body)
 	if err != nil {
 		return nil, err
 	}
 
 	// On 401, try to refresh tokens and retry once
-	if statusCode == 401 && c.RefreshToken != "" {
-		if c.tryRefresh() {
-			data, statusCode, err = c.doRaw(method, path, body)
+	if statusCode == 401 && refreshToken != "" {
+		if c.refreshFrom(token) {
+			token, _ = c.tokens()
+			data, statusCode, err = c.doRaw(ctx, method, path, token, body)
 			if err != nil {
 				return nil, err
 			}
@@ -209,7 +606,7 @@ func (c *Client) do(method, path string, body interface{}) ([]byte, error) {
 	return data, nil
 }
 
-func (c *Client) doRaw(method, path string, body interface{}) ([]byte, int, error) {
+func (c *Client) doRaw(ctx context.Context, method, path, token string, body interface{}) ([]byte, int, error) {
 	url := c.BaseURL + path
 
 	var reqBody io.Reader
@@ -221,14 +618,14 @@ func (c *Client) doRaw(method, path string, body interface{}) ([]byte, int, erro
 		reqBody = bytes.NewReader(data)
 	}
 
-	req, err := http.NewRequest(method, url, reqBody)
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
 	if err != nil {
 		return nil, 0, fmt.Errorf("creating request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	if c.Token != "" {
-		req.Header.Set("Authorization", "Bearer "+c.Token)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
 	}
 
 	resp, err := c.HTTPClient.Do(req)
@@ -245,25 +642,52 @@ func (c *Client) doRaw(method, path string, body interface{}) ([]byte, int, erro
 	return respBody, resp.StatusCode, nil
 }
 
-// tryRefresh attempts to refresh the access token using the refresh token.
-// Returns true if refresh succeeded and tokens were updated.
-func (c *Client) tryRefresh() bool {
+func (c *Client) tokens() (token, refreshToken string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Token, c.RefreshToken
+}
+
+// refreshFrom refreshes the tokens because stale, the access token a
+// request used, has expired. If a concurrent request has already replaced
+// it, that refresh is reused: refresh tokens rotate, so refreshing twice
+// would fail. Returns true if there are new tokens to retry with.
+func (c *Client) refreshFrom(stale string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Token != stale {
+		return true
+	}
+	return c.refreshLocked() == nil
+}
+
+// Refresh exchanges the refresh token for a new token pair and saves it.
+func (c *Client) Refresh() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.refreshLocked()
+}
+
+func (c *Client) refreshLocked() error {
+	if c.RefreshToken == "" {
+		return fmt.Errorf("no refresh token")
+	}
 	body, _ := json.Marshal(map[string]string{"refresh_token": c.RefreshToken})
 
 	req, err := http.NewRequest("POST", c.BaseURL+"/api/auth/refresh", bytes.NewReader(body))
 	if err != nil {
-		return false
+		return fmt.Errorf("creating request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
-		return false
+		return fmt.Errorf("cannot connect to %s: %w", c.BaseURL, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		return false
+		return &APIError{StatusCode: resp.StatusCode, Detail: "refresh failed"}
 	}
 
 	var tokenResp struct {
@@ -271,7 +695,7 @@ func (c *Client) tryRefresh() bool {
 		RefreshToken string `json:"refresh_token"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
-		return false
+		return fmt.Errorf("parsing response: %w", err)
 	}
 
 	// Update client state
@@ -283,5 +707,141 @@ func (c *Client) tryRefresh() bool {
 		fmt.Fprintf(os.Stderr, "warning: could not save refreshed tokens: %s\n", err)
 	}
 
-	return true
+	return nil
+}
+
+// APIToken is a long-lived personal or service-account token. The secret
+// itself is only returned once, by CreateToken.
+type APIToken struct {
+	ID         int     `json:"id"`
+	Name       string  `json:"name"`
+	Kind       string  `json:"kind"` // "personal" or "service_account"
+	Org        string  `json:"org,omitempty"`
+	Prefix     string  `json:"prefix"`
+	CreatedAt  string  `json:"created_at"`
+	LastUsedAt *string `json:"last_used_at"`
+	ExpiresAt  *string `json:"expires_at"`
+}
+
+// CreateTokenRequest describes a token to create. A zero ExpiresInDays
+// means the token never expires. Setting Org creates a service-account
+// token owned by that org instead of a personal one.
+type CreateTokenRequest struct {
+	Name          string `json:"name"`
+	Org           string `json:"org,omitempty"`
+	ExpiresInDays int    `json:"expires_in_days,omitempty"`
+}
+
+// CreateTokenResponse is a newly created token, including its secret.
+type CreateTokenResponse struct {
+	APIToken
+	Token string `json:"token"`
+}
+
+func (c *Client) CreateToken(req CreateTokenRequest) (*CreateTokenResponse, error) {
+	data, err := c.do("POST", "/api/auth/tokens", req)
+	if err != nil {
+		return nil, err
+	}
+	var resp CreateTokenResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("parsing response: %w", err)
+	}
+	return &resp, nil
+}
+
+func (c *Client) ListTokens() ([]APIToken, error) {
+	data, err := c.do("GET", "/api/auth/tokens", nil)
+	if err != nil {
+		return nil, err
+	}
+	var resp []APIToken
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("parsing response: %w", err)
+	}
+	return resp, nil
+}
+
+func (c *Client) RevokeToken(tokenID int) error {
+	_, err := c.do("DELETE", fmt.Sprintf("/api/auth/tokens/%d", tokenID), nil)
+	return err
+}
+
+// Session is a device signed in to the account.
+type Session struct {
+	ID         string  `json:"id"`
+	Device     string  `json:"device"`
+	Client     string  `json:"client"`
+	CreatedAt  string  `json:"created_at"`
+	LastUsedAt *string `json:"last_used_at"`
+	Current    bool    `json:"current"` // the session making the request
+}
+
+func (c *Client) ListSessions() ([]Session, error) {
+	data, err := c.do("GET", "/api/auth/sessions", nil)
+	if err != nil {
+		return nil, err
+	}
+	var resp []Session
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("parsing response: %w", err)
+	}
+	return resp, nil
+}
+
+func (c *Client) RevokeSession(id string) error {
+	_, err := c.do("DELETE", "/api/auth/sessions/"+url.PathEscape(id), nil)
+	return err
+}
+
+// RevokeAllSessions signs out every device, including this one, and
+// returns how many sessions were revoked.
+func (c *Client) RevokeAllSessions() (int, error) {
+	data, err := c.do("POST", "/api/auth/sessions/revoke-all", nil)
+	if err != nil {
+		return 0, err
+	}
+	var resp struct {
+		Revoked int `json:"revoked"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return 0, fmt.Errorf("parsing response: %w", err)
+	}
+	return resp.Revoked, nil
+}
+
+// LogoutTimeout bounds Logout, so a dead server can't hold up clearing
+// local credentials. A variable so tests can shorten it.
+var LogoutTimeout = 5 * time.Second
+
+// Logout revokes the session that owns refreshToken. It doesn't need a
+// valid access token, so an expired session can still be revoked.
+func (c *Client) Logout(refreshToken string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), LogoutTimeout)
+	defer cancel()
+
+	body, _ := json.Marshal(map[string]string{"refresh_token": refreshToken})
+	req, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+"/api/auth/logout", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot connect to %s: %w", c.BaseURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		var errResp struct {
+			Detail string `json:"detail"`
+		}
+		json.NewDecoder(resp.Body).Decode(&errResp)
+		if errResp.Detail == "" {
+			errResp.Detail = http.StatusText(resp.StatusCode)
+		}
+		return &APIError{StatusCode: resp.StatusCode, Detail: errResp.Detail}
+	}
+	return nil
 }
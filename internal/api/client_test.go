@@ -0,0 +1,135 @@
+package api
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/codag-megalith/codag-cli/internal/fakeapi"
+)
+
+// newTestClient returns a client for a fake server with repos registered
+// oldest first.
+func newTestClient(t *testing.T, repos ...[2]string) (*Client, *fakeapi.Server) {
+	t.Helper()
+	t.Setenv("CODAG_REFRESH_TOKEN", "")
+	srv := fakeapi.New(t)
+	for _, r := range repos {
+		srv.AddRepo(r[0], r[1])
+	}
+	return NewClient(srv.URL, srv.AccessToken), srv
+}
+
+var testRepos = [][2]string{
+	{"octo", "widgets"},
+	{"octo", "gadgets"},
+	{"acme", "widgets-api"},
+	{"acme", "billing"},
+	{"octo", "docs"},
+}
+
+func names(repos []RepoResponse) []string {
+	var out []string
+	for _, r := range repos {
+		out = append(out, r.Owner+"/"+r.Name)
+	}
+	return out
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestListReposFollowsCursor(t *testing.T) {
+	client, srv := newTestClient(t, testRepos...)
+	srv.PageSize = 2
+
+	var pages [][]string
+	err := client.EachRepoPage(ListReposOptions{}, func(repos []RepoResponse) error {
+		pages = append(pages, names(repos))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 3 || len(pages[2]) != 1 || pages[2][0] != "octo/docs" {
+		t.Fatalf("expected 3 pages in order, got %v", pages)
+	}
+
+	repos, err := client.ListRepos(ListReposOptions{Owner: "ACME"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(repos); !equal(got, []string{"acme/widgets-api", "acme/billing"}) {
+		t.Fatalf("unexpected owner filter result %v", got)
+	}
+}
+
+func TestListReposLegacyServer(t *testing.T) {
+	client, srv := newTestClient(t, testRepos...)
+	srv.LegacyRepoList = true
+
+	repos, err := client.ListRepos(ListReposOptions{})
+	if err != nil || len(repos) != len(testRepos) {
+		t.Fatalf("expected every repo from a bare array, got %v, %v", names(repos), err)
+	}
+
+	// The server ignores filters, so they're applied locally
+	repos, err = client.ListRepos(ListReposOptions{Owner: "octo", Filter: "*widgets*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(repos); !equal(got, []string{"octo/widgets"}) {
+		t.Fatalf("unexpected filtered result %v", got)
+	}
+
+	if _, err := client.ListRepos(ListReposOptions{Org: "acme"}); !errors.Is(err, ErrOrgFilterUnsupported) {
+		t.Fatalf("expected ErrOrgFilterUnsupported, got %v", err)
+	}
+}
+
+func TestMostRecentRepo(t *testing.T) {
+	for _, legacy := range []bool{false, true} {
+		client, srv := newTestClient(t, testRepos...)
+		srv.LegacyRepoList = legacy
+
+		repo, err := client.MostRecentRepo(ListReposOptions{})
+		if err != nil || repo == nil || repo.Name != "docs" {
+			t.Fatalf("legacy=%v: expected octo/docs, got %+v, %v", legacy, repo, err)
+		}
+		repo, err = client.MostRecentRepo(ListReposOptions{Owner: "acme"})
+		if err != nil || repo == nil || repo.Name != "billing" {
+			t.Fatalf("legacy=%v: expected acme/billing, got %+v, %v", legacy, repo, err)
+		}
+		repo, err = client.MostRecentRepo(ListReposOptions{Filter: "nothing"})
+		if err != nil || repo != nil {
+			t.Fatalf("legacy=%v: expected no repo, got %+v, %v", legacy, repo, err)
+		}
+	}
+}
+
+func TestMatchRepo(t *testing.T) {
+	repo := RepoResponse{Owner: "Octo", Name: "Widgets-API"}
+	for filter, want := range map[string]bool{
+		"widgets":      true,
+		"octo/wid":     true,
+		"OCTO/WIDGETS": true,
+		"acme":         false,
+		"octo/*":       true,
+		"*-api":        true,
+		"widgets-?pi":  true,
+		"acme/*":       false,
+		"[":            false,
+	} {
+		if got := MatchRepo(repo, filter); got != want {
+			t.Errorf("MatchRepo(%q) = %v, want %v", filter, got, want)
+		}
+	}
+}
@@ -1,60 +1,107 @@
 package config
 
 import (
-	"bufio"
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
+
+	"github.com/codag-megalith/codag-cli/internal/remote"
 )
 
 var (
-	CodagHome string
+	CodagHome string // settings and credentials; see dirs.go
 	EnvFile   string
 )
 
+// startupEnv holds the token variables as they were in the OS environment
+// before CodagHome/.env was loaded, for the read-only env credential store.
+var startupEnv = map[string]string{}
+
+// keyringLoaded is set once tokens have been read from the keyring.
+var keyringLoaded bool
+
+// tokenKeys are the variables holding the session's tokens.
+var tokenKeys = []string{"CODAG_ACCESS_TOKEN", "CODAG_REFRESH_TOKEN"}
+
 func init() {
-	home := os.Getenv("CODAG_HOME")
-	if home == "" {
-		userHome, err := os.UserHomeDir()
-		if err != nil {
-			userHome = "."
-		}
-		home = filepath.Join(userHome, ".codag")
-	}
-	CodagHome = home
+	resolveDirs()
 	EnvFile = filepath.Join(CodagHome, ".env")
+
+	for _, k := range tokenKeys {
+		startupEnv[k] = os.Getenv(k)
+	}
 }
 
-// LoadEnv reads ~/.codag/.env into os.Environ.
+// LoadEnv reads the active profile's env file (CodagHome/.env by default)
+// into os.Environ.
 // OS env vars take precedence (matching Python CLI behavior).
+// Tokens are only taken from the file when the file credential store is
+// active; with the keyring store they are read from the keyring instead.
 func LoadEnv() {
-	f, err := os.Open(EnvFile)
-	if err != nil {
-		return
-	}
-	defer f.Close()
-
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-		key, value, found := strings.Cut(line, "=")
-		if !found {
-			continue
+	vars, _ := readEnvFile()
+	origins := map[string]string{}
+	for key := range vars {
+		origins[key] = EnvFile
+	}
+	// Non-default profiles inherit shared settings from CodagHome/.env
+	if activeProfile != DefaultProfile {
+		base, _ := readEnvFileAt(baseEnvFile())
+		for key, value := range base {
+			if _, set := vars[key]; !set && !profileScopedKeys[key] {
+				vars[key] = value
+				origins[key] = baseEnvFile()
+			}
 		}
-		key = strings.TrimSpace(key)
-		value = strings.TrimSpace(value)
-		if key == "" {
-			continue
+	}
+	// Tokens left in the file from before switching stores are stale
+	store := os.Getenv("CODAG_CREDENTIAL_STORE")
+	if store == "" {
+		store = vars["CODAG_CREDENTIAL_STORE"]
+	}
+	if store != "" && store != StoreFile {
+		for _, key := range tokenKeys {
+			delete(vars, key)
 		}
+	}
+	for key, value := range vars {
 		// Only set if not already in environment
 		if _, exists := os.LookupEnv(key); !exists {
 			os.Setenv(key, value)
+			noteLoaded(key, value, origins[key])
 		}
 	}
+
+	if ActiveStoreName() == StoreKeyring && !keyringLoaded && GetAccessToken() == "" {
+		keyringLoaded = true
+		store, err := ActiveStore()
+		if err != nil {
+			return
+		}
+		creds, err := store.Load()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", err)
+			return
+		}
+		if !creds.Empty() {
+			setTokenEnv(creds)
+			noteLoaded("CODAG_ACCESS_TOKEN", creds.AccessToken, StoreKeyring)
+			noteLoaded("CODAG_REFRESH_TOKEN", creds.RefreshToken, StoreKeyring)
+		}
+	}
+}
+
+// readEnvFile parses the active profile's env file.
+func readEnvFile() (map[string]string, error) {
+	return readEnvFileAt(EnvFile)
+}
+
+// readEnvFileAt parses an env file. A missing file yields no variables.
+func readEnvFileAt(path string) (map[string]string, error) {
+	f, err := readDotenv(path)
+	if err != nil {
+		return nil, err
+	}
+	return f.vars(), nil
 }
 
 // GetAccessToken returns the Codag JWT access token from environment.
@@ -72,20 +119,49 @@ func GetToken() string {
 	return GetAccessToken()
 }
 
-// SaveTokens saves access and refresh tokens to ~/.codag/.env.
+// SaveTokens saves access and refresh tokens to the active credential store.
 func SaveTokens(accessToken, refreshToken string) error {
-	if err := SaveEnvVar("CODAG_ACCESS_TOKEN", accessToken); err != nil {
+	store, err := ActiveStore()
+	if err != nil {
+		return err
+	}
+	creds := Credentials{AccessToken: accessToken, RefreshToken: refreshToken}
+	if err := store.Save(creds); err != nil {
 		return err
 	}
-	return SaveEnvVar("CODAG_REFRESH_TOKEN", refreshToken)
+	setTokenEnv(creds)
+	return nil
 }
 
-// ClearTokens removes Codag tokens from ~/.codag/.env.
+// ClearTokens removes Codag tokens from the active credential store.
 func ClearTokens() error {
-	if err := RemoveEnvVar("CODAG_ACCESS_TOKEN"); err != nil {
+	store, err := ActiveStore()
+	if err != nil {
 		return err
 	}
-	return RemoveEnvVar("CODAG_REFRESH_TOKEN")
+	if err := store.Clear(); err != nil {
+		return err
+	}
+	os.Unsetenv("CODAG_ACCESS_TOKEN")
+	os.Unsetenv("CODAG_REFRESH_TOKEN")
+	return nil
+}
+
+// StoreLocation describes where the active credential store keeps tokens.
+func StoreLocation() string {
+	switch ActiveStoreName() {
+	case StoreKeyring:
+		return "the OS keyring"
+	case StoreEnv:
+		return "the environment (CODAG_ACCESS_TOKEN)"
+	}
+	return EnvFile
+}
+
+// setTokenEnv makes tokens visible to the rest of the process.
+func setTokenEnv(c Credentials) {
+	os.Setenv("CODAG_ACCESS_TOKEN", c.AccessToken)
+	os.Setenv("CODAG_REFRESH_TOKEN", c.RefreshToken)
 }
 
 // HasAuth returns true if the user has auth configured.
@@ -105,69 +181,44 @@ func RequireAuth() (string, error) {
 	return "", fmt.Errorf("not logged in — run: codag login")
 }
 
-// SaveEnvVar writes or updates a key in ~/.codag/.env.
+// SaveEnvVar writes or updates a key in CodagHome/.env. Comments and
+// other lines in the file are left as they were.
 func SaveEnvVar(key, value string) error {
 	if err := os.MkdirAll(CodagHome, 0700); err != nil {
 		return fmt.Errorf("creating %s: %w", CodagHome, err)
 	}
 
-	var lines []string
-	replaced := false
-
-	data, err := os.ReadFile(EnvFile)
-	if err == nil {
-		for _, line := range strings.Split(string(data), "\n") {
-			if strings.HasPrefix(strings.TrimSpace(line), key+"=") {
-				lines = append(lines, key+"="+value)
-				replaced = true
-			} else {
-				lines = append(lines, line)
-			}
-		}
-	}
-
-	if !replaced {
-		lines = append(lines, key+"="+value)
-	}
-
-	// Clean up trailing empty lines
-	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
-		lines = lines[:len(lines)-1]
+	f, err := readDotenv(EnvFile)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", EnvFile, err)
 	}
-
-	content := strings.Join(lines, "\n") + "\n"
-	if err := os.WriteFile(EnvFile, []byte(content), 0600); err != nil {
-		return fmt.Errorf("writing %s: %w", EnvFile, err)
+	f.set(key, value)
+	if err := writeDotenv(EnvFile, f); err != nil {
+		return err
 	}
 
 	os.Setenv(key, value)
+	noteLoaded(key, value, EnvFile)
 	return nil
 }
 
-// RemoveEnvVar removes a key from ~/.codag/.env.
+// RemoveEnvVar removes a key from CodagHome/.env.
 func RemoveEnvVar(key string) error {
-	data, err := os.ReadFile(EnvFile)
-	if err != nil {
+	if _, err := os.Stat(EnvFile); err != nil {
 		return nil // file doesn't exist, nothing to remove
 	}
 
-	var lines []string
-	for _, line := range strings.Split(string(data), "\n") {
-		if !strings.HasPrefix(strings.TrimSpace(line), key+"=") {
-			lines = append(lines, line)
-		}
-	}
-
-	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
-		lines = lines[:len(lines)-1]
+	f, err := readDotenv(EnvFile)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", EnvFile, err)
 	}
-
-	content := strings.Join(lines, "\n") + "\n"
-	if err := os.WriteFile(EnvFile, []byte(content), 0600); err != nil {
-		return fmt.Errorf("writing %s: %w", EnvFile, err)
+	f.unset(key)
+	if err := writeDotenv(EnvFile, f); err != nil {
+		return err
 	}
 
 	os.Unsetenv(key)
+	delete(loadedFrom, key)
 	return nil
 }
 
@@ -179,3 +230,18 @@ func GetServerURL() string {
 	}
 	return os.Getenv("CODAG_URL")
 }
+
+// GetGitHosts returns the self-hosted git servers set in CODAG_GIT_HOSTS.
+// An invalid value is ignored; `codag config set` refuses to write one.
+func GetGitHosts() remote.Hosts {
+	hosts, err := remote.ParseHosts(os.Getenv("CODAG_GIT_HOSTS"))
+	if err != nil {
+		return remote.Hosts{}
+	}
+	return hosts
+}
+
+// GetDefaultRepo returns the repo set with `codag repo set-default`.
+func GetDefaultRepo() string {
+	return os.Getenv("CODAG_DEFAULT_REPO")
+}
@@ -0,0 +1,231 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/zalando/go-keyring"
+)
+
+// Credential store backends, selected with CODAG_CREDENTIAL_STORE.
+const (
+	StoreFile    = "file"    // CodagHome/.env (default)
+	StoreKeyring = "keyring" // OS keyring: Secret Service, macOS Keychain, Windows Credential Manager
+	StoreEnv     = "env"     // CODAG_ACCESS_TOKEN / CODAG_REFRESH_TOKEN from the environment, read-only
+)
+
+// StoreNames lists the available credential store backends.
+var StoreNames = []string{StoreFile, StoreKeyring, StoreEnv}
+
+const keyringService = "codag"
+
+var (
+	// ErrReadOnly is returned when saving to a store that can't be written.
+	ErrReadOnly = errors.New("credential store is read-only")
+	// ErrNotFound is returned by a Keyring when no secret is stored.
+	ErrNotFound = errors.New("credential not found")
+)
+
+// Credentials are the tokens for a Codag session.
+type Credentials struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Empty reports whether no tokens are set.
+func (c Credentials) Empty() bool {
+	return c.AccessToken == "" && c.RefreshToken == ""
+}
+
+// CredentialStore persists session tokens.
+type CredentialStore interface {
+	// Name returns the backend name, one of StoreNames.
+	Name() string
+	// Load returns the stored tokens, or empty Credentials if none.
+	Load() (Credentials, error)
+	// Save replaces the stored tokens.
+	Save(Credentials) error
+	// Clear removes the stored tokens.
+	Clear() error
+}
+
+// NewStore returns the credential store backend with the given name.
+func NewStore(name string) (CredentialStore, error) {
+	switch name {
+	case StoreFile:
+		return FileStore{}, nil
+	case StoreKeyring:
+		return &KeyringStore{Keyring: OSKeyring{}, Account: activeProfile}, nil
+	case StoreEnv:
+		return EnvStore{}, nil
+	}
+	return nil, fmt.Errorf("unknown credential store %q (valid: %s)", name, strings.Join(StoreNames, ", "))
+}
+
+// ActiveStoreName returns the configured backend, defaulting to the file store.
+func ActiveStoreName() string {
+	if s := os.Getenv("CODAG_CREDENTIAL_STORE"); s != "" {
+		return s
+	}
+	return StoreFile
+}
+
+// ActiveStore returns the configured credential store.
+func ActiveStore() (CredentialStore, error) {
+	return NewStore(ActiveStoreName())
+}
+
+// SetActiveStore records the backend to use in CodagHome/.env.
+func SetActiveStore(name string) error {
+	if _, err := NewStore(name); err != nil {
+		return err
+	}
+	if name == StoreFile {
+		return RemoveEnvVar("CODAG_CREDENTIAL_STORE")
+	}
+	return SaveEnvVar("CODAG_CREDENTIAL_STORE", name)
+}
+
+// FileStore keeps tokens in plaintext in CodagHome/.env.
+type FileStore struct{}
+
+func (FileStore) Name() string { return StoreFile }
+
+func (FileStore) Load() (Credentials, error) {
+	vars, err := readEnvFile()
+	if err != nil {
+		return Credentials{}, err
+	}
+	return Credentials{
+		AccessToken:  vars["CODAG_ACCESS_TOKEN"],
+		RefreshToken: vars["CODAG_REFRESH_TOKEN"],
+	}, nil
+}
+
+func (FileStore) Save(c Credentials) error {
+	if err := SaveEnvVar("CODAG_ACCESS_TOKEN", c.AccessToken); err != nil {
+		return err
+	}
+	return SaveEnvVar("CODAG_REFRESH_TOKEN", c.RefreshToken)
+}
+
+func (FileStore) Clear() error {
+	if err := RemoveEnvVar("CODAG_ACCESS_TOKEN"); err != nil {
+		return err
+	}
+	return RemoveEnvVar("CODAG_REFRESH_TOKEN")
+}
+
+// Keyring is the subset of an OS keyring the KeyringStore needs.
+// Get returns ErrNotFound when nothing is stored.
+type Keyring interface {
+	Get(service, user string) (string, error)
+	Set(service, user, secret string) error
+	Delete(service, user string) error
+}
+
+// OSKeyring is the system keyring.
+type OSKeyring struct{}
+
+func (OSKeyring) Get(service, user string) (string, error) {
+	s, err := keyring.Get(service, user)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", ErrNotFound
+	}
+	return s, err
+}
+
+func (OSKeyring) Set(service, user, secret string) error {
+	return keyring.Set(service, user, secret)
+}
+
+func (OSKeyring) Delete(service, user string) error {
+	err := keyring.Delete(service, user)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// KeyringStore keeps both tokens as one JSON secret in a keyring, under
+// the "codag" service and an account named after the profile.
+type KeyringStore struct {
+	Keyring Keyring
+	Account string
+}
+
+func (s *KeyringStore) Name() string { return StoreKeyring }
+
+func (s *KeyringStore) Load() (Credentials, error) {
+	secret, err := s.Keyring.Get(keyringService, s.Account)
+	if errors.Is(err, ErrNotFound) {
+		return Credentials{}, nil
+	}
+	if err != nil {
+		return Credentials{}, fmt.Errorf("reading keyring: %w", err)
+	}
+	var c Credentials
+	if err := json.Unmarshal([]byte(secret), &c); err != nil {
+		return Credentials{}, fmt.Errorf("parsing keyring entry: %w", err)
+	}
+	return c, nil
+}
+
+func (s *KeyringStore) Save(c Credentials) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := s.Keyring.Set(keyringService, s.Account, string(data)); err != nil {
+		return fmt.Errorf("writing keyring: %w", err)
+	}
+	return nil
+}
+
+func (s *KeyringStore) Clear() error {
+	err := s.Keyring.Delete(keyringService, s.Account)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("deleting keyring entry: %w", err)
+	}
+	return nil
+}
+
+// EnvStore reads tokens from the process environment as it was when the
+// CLI started, for CI and containers. It can't be written to.
+type EnvStore struct{}
+
+func (EnvStore) Name() string { return StoreEnv }
+
+func (EnvStore) Load() (Credentials, error) {
+	return Credentials{
+		AccessToken:  startupEnv["CODAG_ACCESS_TOKEN"],
+		RefreshToken: startupEnv["CODAG_REFRESH_TOKEN"],
+	}, nil
+}
+
+func (EnvStore) Save(Credentials) error { return ErrReadOnly }
+
+func (EnvStore) Clear() error { return ErrReadOnly }
+
+// MigrateCredentials moves tokens from one store to another and clears
+// the source. If there are tokens and the target is read-only, it
+// returns ErrReadOnly and leaves the source as it was: whether to drop
+// them is up to the caller.
+func MigrateCredentials(from, to CredentialStore) error {
+	creds, err := from.Load()
+	if err != nil {
+		return err
+	}
+	if !creds.Empty() {
+		if err := to.Save(creds); err != nil {
+			return err
+		}
+	}
+	if err := from.Clear(); err != nil && !errors.Is(err, ErrReadOnly) {
+		return err
+	}
+	return nil
+}
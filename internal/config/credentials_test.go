@@ -0,0 +1,189 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// memKeyring is an in-memory Keyring.
+type memKeyring struct {
+	secrets map[string]string
+	err     error // returned by every call when set
+}
+
+func newMemKeyring() *memKeyring {
+	return &memKeyring{secrets: map[string]string{}}
+}
+
+func (k *memKeyring) Get(service, user string) (string, error) {
+	if k.err != nil {
+		return "", k.err
+	}
+	s, ok := k.secrets[service+"/"+user]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return s, nil
+}
+
+func (k *memKeyring) Set(service, user, secret string) error {
+	if k.err != nil {
+		return k.err
+	}
+	k.secrets[service+"/"+user] = secret
+	return nil
+}
+
+func (k *memKeyring) Delete(service, user string) error {
+	if k.err != nil {
+		return k.err
+	}
+	if _, ok := k.secrets[service+"/"+user]; !ok {
+		return ErrNotFound
+	}
+	delete(k.secrets, service+"/"+user)
+	return nil
+}
+
+// tempHome points CodagHome and EnvFile at a temp dir for one test.
+func tempHome(t *testing.T) {
+	t.Helper()
+	home := t.TempDir()
+	oldHome, oldEnvFile := CodagHome, EnvFile
+	CodagHome = home
+	EnvFile = filepath.Join(home, ".env")
+	t.Cleanup(func() { CodagHome, EnvFile = oldHome, oldEnvFile })
+	for _, k := range []string{"CODAG_ACCESS_TOKEN", "CODAG_REFRESH_TOKEN", "CODAG_CREDENTIAL_STORE"} {
+		t.Setenv(k, "")
+		os.Unsetenv(k)
+	}
+}
+
+func TestKeyringStoreRoundTrip(t *testing.T) {
+	store := &KeyringStore{Keyring: newMemKeyring(), Account: "default"}
+
+	creds, err := store.Load()
+	if err != nil || !creds.Empty() {
+		t.Fatalf("expected empty credentials, got %+v (%v)", creds, err)
+	}
+
+	want := Credentials{AccessToken: "a", RefreshToken: "r"}
+	if err := store.Save(want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.Load()
+	if err != nil || got != want {
+		t.Fatalf("expected %+v, got %+v (%v)", want, got, err)
+	}
+
+	if err := store.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Clear(); err != nil {
+		t.Fatalf("second Clear should be a no-op, got %v", err)
+	}
+	if got, _ := store.Load(); !got.Empty() {
+		t.Fatalf("expected cleared credentials, got %+v", got)
+	}
+}
+
+func TestKeyringStoreUnavailable(t *testing.T) {
+	kr := newMemKeyring()
+	kr.err = errors.New("no secret service")
+	store := &KeyringStore{Keyring: kr, Account: "default"}
+
+	if _, err := store.Load(); err == nil {
+		t.Fatal("expected error from unavailable keyring")
+	}
+}
+
+func TestMigrateFileToKeyring(t *testing.T) {
+	tempHome(t)
+	if err := (FileStore{}).Save(Credentials{AccessToken: "a", RefreshToken: "r"}); err != nil {
+		t.Fatal(err)
+	}
+	SaveEnvVar("CODAG_SERVER_URL", "https://codag.internal")
+
+	kr := &KeyringStore{Keyring: newMemKeyring(), Account: "default"}
+	if err := MigrateCredentials(FileStore{}, kr); err != nil {
+		t.Fatal(err)
+	}
+
+	got, _ := kr.Load()
+	if got.AccessToken != "a" || got.RefreshToken != "r" {
+		t.Fatalf("tokens not moved, got %+v", got)
+	}
+
+	data, _ := os.ReadFile(EnvFile)
+	if strings.Contains(string(data), "TOKEN") {
+		t.Fatalf("tokens left in env file:\n%s", data)
+	}
+	if !strings.Contains(string(data), "CODAG_SERVER_URL=https://codag.internal") {
+		t.Fatalf("unrelated settings removed:\n%s", data)
+	}
+}
+
+func TestMigrateIntoReadOnlyStoreKeepsTokens(t *testing.T) {
+	tempHome(t)
+	if err := (FileStore{}).Save(Credentials{AccessToken: "a", RefreshToken: "r"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := MigrateCredentials(FileStore{}, EnvStore{}); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected ErrReadOnly, got %v", err)
+	}
+	if got, _ := (FileStore{}).Load(); got.AccessToken != "a" {
+		t.Fatalf("tokens cleared from the source, got %+v", got)
+	}
+}
+
+func TestEnvStoreIsReadOnly(t *testing.T) {
+	store := EnvStore{}
+	if err := store.Save(Credentials{AccessToken: "a"}); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected ErrReadOnly, got %v", err)
+	}
+	if err := store.Clear(); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected ErrReadOnly, got %v", err)
+	}
+}
+
+func TestSaveTokensUsesActiveStore(t *testing.T) {
+	tempHome(t)
+	t.Setenv("CODAG_CREDENTIAL_STORE", StoreEnv)
+
+	if err := SaveTokens("a", "r"); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected ErrReadOnly from env store, got %v", err)
+	}
+	if _, err := os.Stat(EnvFile); !os.IsNotExist(err) {
+		t.Fatal("env store should not write the env file")
+	}
+}
+
+func TestLoadEnvSkipsFileTokensForOtherStores(t *testing.T) {
+	for _, tc := range []struct {
+		store string
+		want  string
+	}{
+		{StoreFile, "from-file"},
+		{StoreEnv, ""},
+	} {
+		tempHome(t)
+		t.Setenv("CODAG_SERVER_URL", "")
+		os.Unsetenv("CODAG_SERVER_URL")
+		content := "CODAG_CREDENTIAL_STORE=" + tc.store + "\nCODAG_ACCESS_TOKEN=from-file\nCODAG_SERVER_URL=https://codag.example.com\n"
+		if err := os.WriteFile(EnvFile, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		LoadEnv()
+		if got := GetAccessToken(); got != tc.want {
+			t.Errorf("%s store: expected access token %q, got %q", tc.store, tc.want, got)
+		}
+		if got := os.Getenv("CODAG_SERVER_URL"); got != "https://codag.example.com" {
+			t.Errorf("%s store: expected other settings to load, got server %q", tc.store, got)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// Directories codag keeps files in. CodagHome holds settings and
+// credentials, CacheDir files that can be regenerated (the update check),
+// and StateDir data that should survive between runs but isn't a setting
+// (the profile chosen with 'codag profile use').
+//
+// On Linux and other Unix-likes these follow the XDG base directory spec:
+// $XDG_CONFIG_HOME/codag, $XDG_CACHE_HOME/codag and $XDG_STATE_HOME/codag.
+// macOS and Windows keep everything in ~/.codag. CODAG_HOME puts all three
+// in one directory on every platform.
+var (
+	CacheDir string
+	StateDir string
+)
+
+// legacyHome is where everything lived before XDG support.
+var legacyHome string
+
+func resolveDirs() {
+	userHome, err := os.UserHomeDir()
+	if err != nil {
+		userHome = "."
+	}
+	legacyHome = filepath.Join(userHome, ".codag")
+
+	if home := os.Getenv("CODAG_HOME"); home != "" {
+		CodagHome, CacheDir, StateDir = home, home, home
+		return
+	}
+	if !useXDG() {
+		CodagHome, CacheDir, StateDir = legacyHome, legacyHome, legacyHome
+		return
+	}
+	CodagHome = filepath.Join(xdgDir("XDG_CONFIG_HOME", userHome, ".config"), "codag")
+	CacheDir = filepath.Join(xdgDir("XDG_CACHE_HOME", userHome, ".cache"), "codag")
+	StateDir = filepath.Join(xdgDir("XDG_STATE_HOME", userHome, ".local", "state"), "codag")
+}
+
+func useXDG() bool {
+	return runtime.GOOS != "darwin" && runtime.GOOS != "windows"
+}
+
+// xdgDir returns an XDG base directory. The spec says relative paths in
+// the variables are invalid and should be ignored.
+func xdgDir(env, userHome string, fallback ...string) string {
+	if dir := os.Getenv(env); filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(append([]string{userHome}, fallback...)...)
+}
+
+// legacyItems lists files in ~/.codag and the directory each now belongs
+// in. .env goes last: its presence in the new directory marks the
+// migration as done, so a failure part-way is retried next run.
+func legacyItems() []struct{ name, dir string } {
+	return []struct{ name, dir string }{
+		{"profiles", CodagHome},
+		{"profile", StateDir},
+		{".update-check", CacheDir},
+		{".env", CodagHome},
+	}
+}
+
+// MigrateLegacyHome moves files from ~/.codag into the XDG directories the
+// first time they're used. It returns the directory files were moved from,
+// or "" if there was nothing to do.
+func MigrateLegacyHome() (string, error) {
+	if os.Getenv("CODAG_HOME") != "" || legacyHome == "" || legacyHome == CodagHome {
+		return "", nil
+	}
+	if info, err := os.Stat(legacyHome); err != nil || !info.IsDir() {
+		return "", nil
+	}
+	// Don't mix two setups: once the new directory has settings, leave
+	// ~/.codag alone.
+	if _, err := os.Stat(filepath.Join(CodagHome, ".env")); err == nil {
+		return "", nil
+	}
+
+	moved := false
+	for _, item := range legacyItems() {
+		name, dir := item.name, item.dir
+		src := filepath.Join(legacyHome, name)
+		if _, err := os.Lstat(src); err != nil {
+			continue
+		}
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return legacyHome, fmt.Errorf("creating %s: %w", dir, err)
+		}
+		if err := moveItem(src, filepath.Join(dir, name)); err != nil {
+			return legacyHome, fmt.Errorf("moving %s: %w", src, err)
+		}
+		moved = true
+	}
+	if !moved {
+		return "", nil
+	}
+	// Only removed if nothing else was left in it.
+	os.Remove(legacyHome)
+	return legacyHome, nil
+}
+
+// moveItem renames src to dst, copying when they're on different
+// filesystems.
+func moveItem(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	if err := copyItem(src, dst); err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+func copyItem(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		if err := os.MkdirAll(dst, 0700); err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := copyItem(filepath.Join(src, e.Name()), filepath.Join(dst, e.Name())); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestResolveDirs(t *testing.T) {
+	oldHome, oldCache, oldState, oldLegacy := CodagHome, CacheDir, StateDir, legacyHome
+	t.Cleanup(func() { CodagHome, CacheDir, StateDir, legacyHome = oldHome, oldCache, oldState, oldLegacy })
+
+	userHome := t.TempDir()
+	t.Setenv("HOME", userHome)
+	t.Setenv("CODAG_HOME", "")
+	t.Setenv("XDG_CONFIG_HOME", "/xdg/config")
+	t.Setenv("XDG_CACHE_HOME", "relative/ignored")
+	t.Setenv("XDG_STATE_HOME", "/xdg/state")
+
+	resolveDirs()
+	if !useXDG() {
+		if CodagHome != filepath.Join(userHome, ".codag") || CacheDir != CodagHome {
+			t.Fatalf("expected ~/.codag on %s, got %s", runtime.GOOS, CodagHome)
+		}
+	} else {
+		if CodagHome != "/xdg/config/codag" {
+			t.Fatalf("config dir: got %s", CodagHome)
+		}
+		if CacheDir != filepath.Join(userHome, ".cache", "codag") {
+			t.Fatalf("relative XDG_CACHE_HOME should be ignored, got %s", CacheDir)
+		}
+		if StateDir != "/xdg/state/codag" {
+			t.Fatalf("state dir: got %s", StateDir)
+		}
+
+		t.Setenv("XDG_STATE_HOME", "")
+		resolveDirs()
+		if StateDir != filepath.Join(userHome, ".local", "state", "codag") {
+			t.Fatalf("state dir should default to ~/.local/state/codag, got %s", StateDir)
+		}
+	}
+
+	t.Setenv("CODAG_HOME", "/custom")
+	resolveDirs()
+	if CodagHome != "/custom" || CacheDir != "/custom" || StateDir != "/custom" {
+		t.Fatalf("CODAG_HOME should override everything, got %s %s %s", CodagHome, CacheDir, StateDir)
+	}
+}
+
+func TestMigrateLegacyHome(t *testing.T) {
+	oldHome, oldCache, oldState, oldLegacy := CodagHome, CacheDir, StateDir, legacyHome
+	t.Cleanup(func() { CodagHome, CacheDir, StateDir, legacyHome = oldHome, oldCache, oldState, oldLegacy })
+	t.Setenv("CODAG_HOME", "")
+
+	root := t.TempDir()
+	legacyHome = filepath.Join(root, ".codag")
+	CodagHome = filepath.Join(root, "config", "codag")
+	CacheDir = filepath.Join(root, "cache", "codag")
+	StateDir = filepath.Join(root, "state", "codag")
+
+	os.MkdirAll(filepath.Join(legacyHome, "profiles"), 0700)
+	os.WriteFile(filepath.Join(legacyHome, ".env"), []byte("CODAG_ACCESS_TOKEN=a\n"), 0600)
+	os.WriteFile(filepath.Join(legacyHome, "profiles", "work.env"), []byte("CODAG_SERVER_URL=https://w\n"), 0600)
+	os.WriteFile(filepath.Join(legacyHome, "profile"), []byte("work\n"), 0600)
+	os.WriteFile(filepath.Join(legacyHome, ".update-check"), []byte("{}"), 0600)
+
+	from, err := MigrateLegacyHome()
+	if err != nil || from != legacyHome {
+		t.Fatalf("expected migration from %s, got %q (%v)", legacyHome, from, err)
+	}
+	for _, p := range []string{
+		filepath.Join(CodagHome, ".env"),
+		filepath.Join(CodagHome, "profiles", "work.env"),
+		filepath.Join(StateDir, "profile"),
+		filepath.Join(CacheDir, ".update-check"),
+	} {
+		if _, err := os.Stat(p); err != nil {
+			t.Fatalf("expected %s after migration: %v", p, err)
+		}
+	}
+	if info, err := os.Stat(filepath.Join(CodagHome, ".env")); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("credentials should stay private, got %v", info.Mode())
+	}
+	if _, err := os.Stat(legacyHome); !os.IsNotExist(err) {
+		t.Fatal("empty ~/.codag should be removed")
+	}
+
+	// Nothing left to do the second time
+	if from, err := MigrateLegacyHome(); from != "" || err != nil {
+		t.Fatalf("expected no-op, got %q (%v)", from, err)
+	}
+}
+
+func TestMigrateLegacyHomeKeepsExistingSetup(t *testing.T) {
+	oldHome, oldCache, oldLegacy := CodagHome, CacheDir, legacyHome
+	t.Cleanup(func() { CodagHome, CacheDir, legacyHome = oldHome, oldCache, oldLegacy })
+	t.Setenv("CODAG_HOME", "")
+
+	root := t.TempDir()
+	legacyHome = filepath.Join(root, ".codag")
+	CodagHome = filepath.Join(root, "config", "codag")
+	CacheDir = CodagHome
+	os.MkdirAll(legacyHome, 0700)
+	os.MkdirAll(CodagHome, 0700)
+	os.WriteFile(filepath.Join(legacyHome, ".env"), []byte("OLD=1\n"), 0600)
+	os.WriteFile(filepath.Join(CodagHome, ".env"), []byte("NEW=1\n"), 0600)
+
+	if from, err := MigrateLegacyHome(); from != "" || err != nil {
+		t.Fatalf("expected no migration, got %q (%v)", from, err)
+	}
+	data, _ := os.ReadFile(filepath.Join(CodagHome, ".env"))
+	if string(data) != "NEW=1\n" {
+		t.Fatalf("existing settings were overwritten: %q", data)
+	}
+}
+
+func TestCurrentProfileIsState(t *testing.T) {
+	oldHome, oldEnvFile, oldState := CodagHome, EnvFile, StateDir
+	t.Cleanup(func() { CodagHome, EnvFile, StateDir = oldHome, oldEnvFile, oldState })
+	t.Setenv("CODAG_PROFILE", "")
+
+	root := t.TempDir()
+	CodagHome = filepath.Join(root, "config", "codag")
+	EnvFile = filepath.Join(CodagHome, ".env")
+	StateDir = filepath.Join(root, "state", "codag")
+
+	if err := AddProfile("work", "https://codag.work"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetCurrentProfile("work"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(StateDir, "profile")); err != nil {
+		t.Fatalf("expected the current profile in the state dir: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(CodagHome, "profile")); !os.IsNotExist(err) {
+		t.Fatal("the current profile shouldn't be written to the config dir")
+	}
+	if got := SelectProfile(""); got != "work" {
+		t.Fatalf("expected work, got %s", got)
+	}
+}
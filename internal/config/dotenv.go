@@ -0,0 +1,290 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// A dotenv file is parsed into entries that remember their original text,
+// so rewriting one key leaves comments, blank lines, formatting and lines
+// we don't understand exactly as they were.
+//
+// Supported syntax:
+//
+//	KEY=value             # unquoted; a # after whitespace starts a comment
+//	export KEY=value      # optional export prefix
+//	KEY='literal $value'  # single quotes: no escapes, may span lines
+//	KEY="a\nb \"c\""      # double quotes: \n \r \t \" \\ \$ escapes, may span lines
+//	KEY=#not-a-comment    # # only starts a comment after whitespace
+
+var dotenvKeyRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// unquotedSafeRe matches values written without quotes.
+var unquotedSafeRe = regexp.MustCompile(`^[A-Za-z0-9_./:@%+,=~-]+$`)
+
+type dotenvEntry struct {
+	raw     string // original text, possibly several lines; used when unchanged
+	key     string // empty for comments, blank and unparseable lines
+	value   string
+	export  bool
+	comment string // trailing inline comment, including its leading space
+}
+
+type dotenvFile struct {
+	entries []dotenvEntry
+	crlf    bool // written back with Windows line endings
+}
+
+// parseDotenv parses dotenv text. It never fails: lines it can't parse are
+// kept verbatim and contribute no variables.
+func parseDotenv(data string) *dotenvFile {
+	f := &dotenvFile{crlf: strings.Contains(data, "\r\n")}
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	data = strings.TrimSuffix(data, "\n")
+	if data == "" {
+		return f
+	}
+	lines := strings.Split(data, "\n")
+	for i := 0; i < len(lines); {
+		entry, used := parseDotenvEntry(lines[i:])
+		f.entries = append(f.entries, entry)
+		i += used
+	}
+	return f
+}
+
+// parseDotenvEntry parses the entry starting at lines[0] and returns how
+// many lines it spans.
+func parseDotenvEntry(lines []string) (dotenvEntry, int) {
+	line := lines[0]
+	unknown := dotenvEntry{raw: line}
+
+	rest := strings.TrimLeft(line, " \t")
+	if rest == "" || strings.HasPrefix(rest, "#") {
+		return unknown, 1
+	}
+
+	var e dotenvEntry
+	if after, ok := strings.CutPrefix(rest, "export"); ok && after != "" && (after[0] == ' ' || after[0] == '\t') {
+		e.export = true
+		rest = strings.TrimLeft(after, " \t")
+	}
+
+	key, value, found := strings.Cut(rest, "=")
+	key = strings.TrimRight(key, " \t")
+	if !found || !dotenvKeyRe.MatchString(key) {
+		return unknown, 1
+	}
+	e.key = key
+	e.raw = line
+	trimmed := strings.TrimLeft(value, " \t")
+	if trimmed == "" {
+		return e, 1
+	}
+	if trimmed[0] == '#' && len(trimmed) < len(value) {
+		// KEY= # comment
+		e.comment = " " + trimmed
+		return e, 1
+	}
+	value = trimmed
+
+	switch quote := value[0]; quote {
+	case '\'', '"':
+		// Join lines until the closing quote.
+		text := value[1:]
+		used := 1
+		for {
+			if end := closingQuote(text, quote); end >= 0 {
+				tail := text[end+1:]
+				comment, ok := trailingComment(tail)
+				if !ok {
+					return unknown, 1
+				}
+				e.value = text[:end]
+				if quote == '"' {
+					e.value = unescapeDouble(e.value)
+				}
+				e.comment = comment
+				e.raw = strings.Join(lines[:used], "\n")
+				return e, used
+			}
+			if used == len(lines) {
+				return unknown, 1 // unterminated quote
+			}
+			text += "\n" + lines[used]
+			used++
+		}
+	}
+
+	// Unquoted: the value ends at a # preceded by whitespace.
+	for i := 1; i < len(value); i++ {
+		if value[i] == '#' && (value[i-1] == ' ' || value[i-1] == '\t') {
+			e.comment = " " + value[i:]
+			value = value[:i]
+			break
+		}
+	}
+	e.value = strings.TrimRight(value, " \t")
+	return e, 1
+}
+
+// closingQuote returns the index of the unescaped closing quote in s.
+func closingQuote(s string, quote byte) int {
+	for i := 0; i < len(s); i++ {
+		if quote == '"' && s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] == quote {
+			return i
+		}
+	}
+	return -1
+}
+
+// trailingComment checks what follows a closing quote: nothing, or
+// whitespace and a comment.
+func trailingComment(tail string) (string, bool) {
+	trimmed := strings.TrimLeft(tail, " \t")
+	if trimmed == "" {
+		return "", true
+	}
+	if strings.HasPrefix(trimmed, "#") && len(trimmed) < len(tail) {
+		return " " + trimmed, true
+	}
+	return "", false
+}
+
+func unescapeDouble(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '"', '\\', '$', '\'':
+			b.WriteByte(s[i])
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// formatEnvValue quotes a value if it needs it to read back unchanged.
+func formatEnvValue(v string) string {
+	if v == "" || unquotedSafeRe.MatchString(v) {
+		return v
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + r.Replace(v) + `"`
+}
+
+// vars returns the variables defined in the file. Later definitions win.
+func (f *dotenvFile) vars() map[string]string {
+	vars := map[string]string{}
+	for _, e := range f.entries {
+		if e.key != "" {
+			vars[e.key] = e.value
+		}
+	}
+	return vars
+}
+
+// set updates the last definition of key, dropping earlier ones, or
+// appends a new one.
+func (f *dotenvFile) set(key, value string) {
+	last := -1
+	for i, e := range f.entries {
+		if e.key == key {
+			last = i
+		}
+	}
+	if last < 0 {
+		f.entries = append(f.entries, dotenvEntry{key: key, value: value})
+		f.entries[len(f.entries)-1].raw = f.entries[len(f.entries)-1].format()
+		return
+	}
+	e := &f.entries[last]
+	if e.value != value {
+		e.value = value
+		e.raw = e.format()
+	}
+	f.remove(key, last)
+}
+
+// unset removes every definition of key.
+func (f *dotenvFile) unset(key string) {
+	f.remove(key, -1)
+}
+
+// remove drops definitions of key, except the entry at index keep.
+func (f *dotenvFile) remove(key string, keep int) {
+	kept := f.entries[:0]
+	for i, e := range f.entries {
+		if e.key != key || i == keep {
+			kept = append(kept, e)
+		}
+	}
+	f.entries = kept
+}
+
+// format renders an entry, keeping its export prefix and inline comment.
+func (e dotenvEntry) format() string {
+	s := e.key + "=" + formatEnvValue(e.value)
+	if e.export {
+		s = "export " + s
+	}
+	return s + e.comment
+}
+
+// String renders the file, ending with a newline.
+func (f *dotenvFile) String() string {
+	if len(f.entries) == 0 {
+		return ""
+	}
+	eol := "\n"
+	if f.crlf {
+		eol = "\r\n"
+	}
+	var b strings.Builder
+	for _, e := range f.entries {
+		b.WriteString(strings.ReplaceAll(e.raw, "\n", eol))
+		b.WriteString(eol)
+	}
+	return b.String()
+}
+
+// readDotenv reads and parses a dotenv file. A missing file is empty.
+func readDotenv(path string) (*dotenvFile, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &dotenvFile{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseDotenv(string(data)), nil
+}
+
+// writeDotenv writes a dotenv file readable only by the user.
+func writeDotenv(path string, f *dotenvFile) error {
+	if err := os.WriteFile(path, []byte(f.String()), 0600); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
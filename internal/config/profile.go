@@ -0,0 +1,201 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultProfile is the profile stored in CodagHome/.env itself.
+const DefaultProfile = "default"
+
+var profileNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// activeProfile is the profile whose file EnvFile points at.
+var activeProfile = DefaultProfile
+
+// profileScopedKeys belong to a single profile. Other keys set in
+// CodagHome/.env (proxy, CA bundle, …) apply to every profile unless the
+// profile overrides them.
+var profileScopedKeys = map[string]bool{
+	"CODAG_ACCESS_TOKEN":     true,
+	"CODAG_REFRESH_TOKEN":    true,
+	"CODAG_SERVER_URL":       true,
+	"CODAG_URL":              true,
+	"CODAG_CREDENTIAL_STORE": true,
+	"CODAG_DEFAULT_REPO":     true,
+}
+
+// baseEnvFile is CodagHome/.env, which also holds the default profile.
+func baseEnvFile() string {
+	return filepath.Join(CodagHome, ".env")
+}
+
+// ProfilesDir is where non-default profiles are stored.
+func ProfilesDir() string {
+	return filepath.Join(CodagHome, "profiles")
+}
+
+// ProfileEnvFile returns the env file holding a profile's settings.
+func ProfileEnvFile(name string) string {
+	if name == DefaultProfile {
+		return baseEnvFile()
+	}
+	return filepath.Join(ProfilesDir(), name+".env")
+}
+
+// currentProfileFile records the profile chosen with `codag profile use`.
+// It's state rather than a setting, so it lives in StateDir.
+func currentProfileFile() string {
+	return filepath.Join(StateDir, "profile")
+}
+
+// ActiveProfile returns the profile in use.
+func ActiveProfile() string {
+	return activeProfile
+}
+
+// SelectProfile returns the profile to use: the flag value, then
+// CODAG_PROFILE, then the one saved by `codag profile use`, then default.
+func SelectProfile(flag string) string {
+	if flag != "" {
+		return flag
+	}
+	if p := os.Getenv("CODAG_PROFILE"); p != "" {
+		return p
+	}
+	if data, err := os.ReadFile(currentProfileFile()); err == nil {
+		if p := strings.TrimSpace(string(data)); p != "" {
+			return p
+		}
+	}
+	return DefaultProfile
+}
+
+// UseProfile switches EnvFile to the named profile for this process.
+func UseProfile(name string) error {
+	if !ProfileExists(name) {
+		return fmt.Errorf("profile %q does not exist — run: codag profile list", name)
+	}
+	activeProfile = name
+	EnvFile = ProfileEnvFile(name)
+	keyringLoaded = false
+	return nil
+}
+
+// ValidateProfileName checks a name is usable as a file name.
+func ValidateProfileName(name string) error {
+	if !profileNameRe.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q (use letters, digits, - and _)", name)
+	}
+	return nil
+}
+
+// ProfileExists reports whether a profile has been created. The default
+// profile always exists.
+func ProfileExists(name string) bool {
+	if name == DefaultProfile {
+		return true
+	}
+	if ValidateProfileName(name) != nil {
+		return false
+	}
+	_, err := os.Stat(ProfileEnvFile(name))
+	return err == nil
+}
+
+// ListProfiles returns all profile names, default first.
+func ListProfiles() ([]string, error) {
+	names := []string{DefaultProfile}
+	entries, err := os.ReadDir(ProfilesDir())
+	if os.IsNotExist(err) {
+		return names, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var others []string
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".env")
+		if ok && !e.IsDir() && ValidateProfileName(name) == nil && name != DefaultProfile {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	return append(names, others...), nil
+}
+
+// AddProfile creates a profile, optionally pinned to a server URL.
+func AddProfile(name, serverURL string) error {
+	if err := ValidateProfileName(name); err != nil {
+		return err
+	}
+	if ProfileExists(name) {
+		return fmt.Errorf("profile %q already exists", name)
+	}
+	if err := os.MkdirAll(ProfilesDir(), 0700); err != nil {
+		return fmt.Errorf("creating %s: %w", ProfilesDir(), err)
+	}
+	content := "# codag profile: " + name + "\n"
+	if serverURL != "" {
+		content += "CODAG_SERVER_URL=" + formatEnvValue(serverURL) + "\n"
+	}
+	path := ProfileEnvFile(name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
+
+// RemoveProfile deletes a profile and any keyring entry it owns. If it was
+// the saved current profile, the default profile becomes current again.
+func RemoveProfile(name string) error {
+	if name == DefaultProfile {
+		return fmt.Errorf("the default profile can't be removed")
+	}
+	if !ProfileExists(name) {
+		return fmt.Errorf("profile %q does not exist", name)
+	}
+
+	vars, _ := readEnvFileAt(ProfileEnvFile(name))
+	if vars["CODAG_CREDENTIAL_STORE"] == StoreKeyring {
+		if err := (&KeyringStore{Keyring: OSKeyring{}, Account: name}).Clear(); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Remove(ProfileEnvFile(name)); err != nil {
+		return err
+	}
+	if data, err := os.ReadFile(currentProfileFile()); err == nil && strings.TrimSpace(string(data)) == name {
+		os.Remove(currentProfileFile())
+	}
+	return nil
+}
+
+// SetCurrentProfile saves the profile used when neither --profile nor
+// CODAG_PROFILE is given.
+func SetCurrentProfile(name string) error {
+	if !ProfileExists(name) {
+		return fmt.Errorf("profile %q does not exist", name)
+	}
+	if name == DefaultProfile {
+		err := os.Remove(currentProfileFile())
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if err := os.MkdirAll(StateDir, 0700); err != nil {
+		return fmt.Errorf("creating %s: %w", StateDir, err)
+	}
+	return os.WriteFile(currentProfileFile(), []byte(name+"\n"), 0600)
+}
+
+// ProfileSettings returns the variables stored in a profile's own file.
+func ProfileSettings(name string) (map[string]string, error) {
+	return readEnvFileAt(ProfileEnvFile(name))
+}
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/codag-megalith/codag-cli/internal/remote"
+)
+
+// Setting is a key that can be managed with `codag config`.
+type Setting struct {
+	Name        string // short name, e.g. "server-url"
+	Env         string // environment variable, e.g. "CODAG_SERVER_URL"
+	Description string
+	Default     string
+	Secret      bool   // masked when shown
+	ManagedBy   string // command that owns the key; set/unset are refused
+	validate    func(string) error
+}
+
+// Settings lists the keys `codag config` knows about, in display order.
+var Settings = []Setting{
+	{Name: "server-url", Env: "CODAG_SERVER_URL", Description: "API server URL", Default: "https://api.codag.ai", validate: validateHTTPURL},
+	{Name: "credential-store", Env: "CODAG_CREDENTIAL_STORE", Description: "Where tokens are stored", Default: StoreFile, ManagedBy: "codag auth storage"},
+	{Name: "proxy", Env: "CODAG_PROXY", Description: "HTTP(S) proxy for API requests", validate: validateProxyURL},
+	{Name: "ca-bundle", Env: "CODAG_CA_BUNDLE", Description: "Extra CA certificates (PEM)", validate: validateFile},
+	{Name: "client-cert", Env: "CODAG_CLIENT_CERT", Description: "TLS client certificate (PEM)", validate: validateFile},
+	{Name: "client-key", Env: "CODAG_CLIENT_KEY", Description: "TLS client key (PEM)", validate: validateFile},
+	{Name: "default-repo", Env: "CODAG_DEFAULT_REPO", Description: "Repo used outside a checkout, as an ID or owner/name"},
+	{Name: "git-hosts", Env: "CODAG_GIT_HOSTS", Description: "Self-hosted git servers, as host=provider,...", validate: validateGitHosts},
+	{Name: "debug", Env: "CODAG_DEBUG", Description: "Log HTTP requests to stderr", Default: "false", validate: validateBool},
+	{Name: "har", Env: "CODAG_HAR", Description: "Record HTTP traffic to a HAR file"},
+	{Name: "access-token", Env: "CODAG_ACCESS_TOKEN", Description: "Access token", Secret: true, ManagedBy: "codag login"},
+	{Name: "refresh-token", Env: "CODAG_REFRESH_TOKEN", Description: "Refresh token", Secret: true, ManagedBy: "codag login"},
+}
+
+// LookupSetting finds a setting by short name or environment variable,
+// case-insensitively. "server_url" and "CODAG_SERVER_URL" both match
+// "server-url".
+func LookupSetting(name string) (Setting, bool) {
+	norm := strings.ToLower(strings.ReplaceAll(name, "_", "-"))
+	norm = strings.TrimPrefix(norm, "codag-")
+	for _, s := range Settings {
+		if s.Name == norm {
+			return s, true
+		}
+	}
+	return Setting{}, false
+}
+
+// Validate checks a value before it's written.
+func (s Setting) Validate(value string) error {
+	if s.ManagedBy != "" {
+		return fmt.Errorf("%s is managed by '%s'", s.Name, s.ManagedBy)
+	}
+	if s.validate == nil {
+		return nil
+	}
+	if err := s.validate(value); err != nil {
+		return fmt.Errorf("invalid %s: %w", s.Name, err)
+	}
+	return nil
+}
+
+// Source says where an effective value came from.
+type Source string
+
+const (
+	SourceFlag    Source = "flag"
+	SourceEnv     Source = "env"
+	SourceFile    Source = "file"
+	SourceKeyring Source = "keyring"
+	SourceProject Source = "project"
+	SourceDefault Source = "default"
+)
+
+// Value is a setting's effective value and where it came from.
+type Value struct {
+	Value  string
+	Source Source
+	Origin string // flag name, variable name or file path
+}
+
+// loadedFrom records the variables LoadEnv set, and the file (or
+// "keyring") each came from. Anything else in the environment was set
+// outside codag.
+var loadedFrom = map[string]loaded{}
+
+type loaded struct {
+	value, origin string
+}
+
+// noteLoaded records that LoadEnv set key from origin.
+func noteLoaded(key, value, origin string) {
+	loadedFrom[key] = loaded{value: value, origin: origin}
+}
+
+// Effective returns the value of s currently in effect, ignoring flags.
+func Effective(s Setting) Value {
+	value, ok := os.LookupEnv(s.Env)
+	if !ok || value == "" {
+		// CODAG_URL is the older name for the server URL, still set by
+		// editors' MCP configs.
+		if s.Env == "CODAG_SERVER_URL" {
+			if v := Effective(Setting{Env: "CODAG_URL"}); v.Source != SourceDefault {
+				return v
+			}
+		}
+		return Value{Value: s.Default, Source: SourceDefault}
+	}
+	if l, ok := loadedFrom[s.Env]; ok && l.value == value {
+		if l.origin == StoreKeyring {
+			return Value{Value: value, Source: SourceKeyring, Origin: "OS keyring"}
+		}
+		return Value{Value: value, Source: SourceFile, Origin: l.origin}
+	}
+	return Value{Value: value, Source: SourceEnv, Origin: s.Env}
+}
+
+// Mask hides all but the ends of a secret.
+func Mask(value string) string {
+	if value == "" {
+		return ""
+	}
+	if len(value) <= 12 {
+		return "********"
+	}
+	return value[:4] + "…" + value[len(value)-4:]
+}
+
+func validateHTTPURL(v string) error {
+	u, err := url.Parse(v)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an http(s) URL", v)
+	}
+	return nil
+}
+
+func validateProxyURL(v string) error {
+	u, err := url.Parse(v)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("%q is not a proxy URL", v)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+		return nil
+	}
+	return fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+}
+
+func validateFile(v string) error {
+	info, err := os.Stat(v)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", v)
+	}
+	return nil
+}
+
+func validateGitHosts(v string) error {
+	_, err := remote.ParseHosts(v)
+	return err
+}
+
+func validateBool(v string) error {
+	if _, err := ParseBool(v); err != nil {
+		return fmt.Errorf("%q is not true or false", v)
+	}
+	return nil
+}
+
+// ParseBool parses a boolean setting: anything strconv.ParseBool accepts,
+// plus yes/no and on/off in any case.
+func ParseBool(v string) (bool, error) {
+	switch strings.ToLower(v) {
+	case "yes", "on":
+		return true, nil
+	case "no", "off":
+		return false, nil
+	}
+	return strconv.ParseBool(strings.ToLower(v))
+}
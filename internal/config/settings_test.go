@@ -0,0 +1,95 @@
+package config
+
+import (
+	"os"
+	"testing"
+)
+
+func TestLookupSetting(t *testing.T) {
+	for _, name := range []string{"server-url", "server_url", "CODAG_SERVER_URL", "Server-Url"} {
+		s, ok := LookupSetting(name)
+		if !ok || s.Env != "CODAG_SERVER_URL" {
+			t.Fatalf("LookupSetting(%q) = %+v, %v", name, s, ok)
+		}
+	}
+	if _, ok := LookupSetting("nope"); ok {
+		t.Fatal("unknown setting should not match")
+	}
+}
+
+func TestEffectiveSource(t *testing.T) {
+	tempHome(t)
+	s, _ := LookupSetting("server-url")
+	t.Setenv("CODAG_SERVER_URL", "")
+	os.Unsetenv("CODAG_SERVER_URL")
+	t.Setenv("CODAG_URL", "")
+	os.Unsetenv("CODAG_URL")
+
+	if v := Effective(s); v.Source != SourceDefault || v.Value != s.Default {
+		t.Fatalf("expected default, got %+v", v)
+	}
+
+	os.WriteFile(EnvFile, []byte("CODAG_SERVER_URL=https://file.example\n"), 0600)
+	LoadEnv()
+	if v := Effective(s); v.Source != SourceFile || v.Origin != EnvFile {
+		t.Fatalf("expected file origin, got %+v", v)
+	}
+
+	os.Setenv("CODAG_SERVER_URL", "https://env.example")
+	if v := Effective(s); v.Source != SourceEnv || v.Value != "https://env.example" {
+		t.Fatalf("expected env origin, got %+v", v)
+	}
+
+	os.Unsetenv("CODAG_SERVER_URL")
+	os.Setenv("CODAG_URL", "https://mcp.example")
+	if v := Effective(s); v.Source != SourceEnv || v.Origin != "CODAG_URL" {
+		t.Fatalf("expected CODAG_URL fallback, got %+v", v)
+	}
+}
+
+func TestValidateSetting(t *testing.T) {
+	cases := []struct {
+		name, value string
+		ok          bool
+	}{
+		{"server-url", "https://codag.internal", true},
+		{"server-url", "codag.internal", false},
+		{"proxy", "socks5://127.0.0.1:1080", true},
+		{"proxy", "ftp://proxy", false},
+		{"debug", "on", true},
+		{"debug", "maybe", false},
+		{"ca-bundle", "/does/not/exist.pem", false},
+		{"credential-store", "keyring", false},
+	}
+	for _, c := range cases {
+		s, _ := LookupSetting(c.name)
+		if err := s.Validate(c.value); (err == nil) != c.ok {
+			t.Fatalf("Validate(%s=%q): got err=%v, want ok=%v", c.name, c.value, err, c.ok)
+		}
+	}
+}
+
+func TestParseBool(t *testing.T) {
+	for v, want := range map[string]bool{
+		"1": true, "t": true, "T": true, "true": true, "TRUE": true, "Yes": true, "on": true,
+		"0": false, "f": false, "False": false, "no": false, "OFF": false,
+	} {
+		if got, err := ParseBool(v); err != nil || got != want {
+			t.Fatalf("ParseBool(%q) = %v, %v; want %v", v, got, err, want)
+		}
+	}
+	for _, v := range []string{"", "maybe", "2"} {
+		if _, err := ParseBool(v); err == nil {
+			t.Fatalf("ParseBool(%q) should fail", v)
+		}
+	}
+}
+
+func TestMask(t *testing.T) {
+	if got := Mask("codag_pat_0123456789abcdef"); got != "coda…cdef" {
+		t.Fatalf("unexpected mask %q", got)
+	}
+	if got := Mask("short"); got != "********" {
+		t.Fatalf("short secrets should be fully masked, got %q", got)
+	}
+}
@@ -0,0 +1,1035 @@
+// Package fakeapi is an in-process stand-in for the Codag API, used to
+// drive commands and the MCP client end to end in tests.
+//
+// A Server starts with one valid access/refresh token pair and no repos.
+// Tests script behaviour by setting fields before (or between) requests,
+// or by overriding any route with Handle.
+package fakeapi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codag-megalith/codag-cli/internal/version"
+)
+
+// Repo is a registered repo as returned by /api/repos.
+type Repo struct {
+	ID            int     `json:"id"`
+	Name          string  `json:"name"`
+	Owner         string  `json:"owner"`
+	GithubURL     string  `json:"github_url"`
+	Provider      string  `json:"provider"`
+	Host          string  `json:"host"`
+	URL           string  `json:"url"`
+	LastIndexedAt *string `json:"last_indexed_at"`
+}
+
+// Stats is the payload of /api/stats.
+type Stats struct {
+	RepoID           int  `json:"repo_id"`
+	PRsIndexed       int  `json:"prs_indexed"`
+	FilesWithSignals int  `json:"files_with_signals"`
+	TotalSignals     int  `json:"total_signals"`
+	DangerSignals    int  `json:"danger_signals"`
+	Indexing         bool `json:"indexing"`
+	Cancelled        bool `json:"cancelled"`
+}
+
+// Request is a request the server received.
+type Request struct {
+	Method string
+	Path   string
+	Query  string
+	Body   string
+	Auth   string
+
+	// ClientVersion is the X-Codag-Client-Version header.
+	ClientVersion string
+}
+
+// Server is a scriptable fake Codag API.
+type Server struct {
+	*httptest.Server
+
+	mu  sync.Mutex
+	mux *http.ServeMux
+
+	// AccessToken and RefreshToken are the currently valid tokens.
+	// Refreshing rotates both.
+	AccessToken  string
+	RefreshToken string
+
+	// Login is the GitHub login reported after device auth and by /api/console/me.
+	Login string
+
+	// DevicePending is how many times /api/auth/device/token answers 428
+	// before authorizing. DeviceExpired makes it answer 410 instead.
+	DevicePending int
+	DeviceExpired bool
+
+	// AuthorizeDenied makes /api/auth/authorize redirect back with
+	// error=access_denied, as when the user declines in the browser.
+	// NoAuthorize makes it 404 like servers that predate browser login.
+	AuthorizeDenied bool
+	NoAuthorize     bool
+
+	// PageSize paginates /api/repos when > 0. LegacyRepoList returns a bare
+	// array like servers that predate pagination.
+	PageSize       int
+	LegacyRepoList bool
+
+	// Stats holds per-repo stats sequences. Each /api/stats call returns
+	// the next entry; the last entry repeats. /api/stats/bulk advances
+	// every repo it reports on. NoBulkStats makes the bulk endpoint 404
+	// like servers that predate it. Cancelling a backfill while the next
+	// entry is indexing ends the sequence there, marked cancelled.
+	Stats       map[int][]Stats
+	NoBulkStats bool
+
+	// BackfillStatus is returned by /api/repos/{id}/backfill and
+	// /api/repos/{id}/backfill/incremental ("started" by default).
+	// NoIncrementalBackfill makes the latter 404 like servers that
+	// predate it.
+	BackfillStatus        string
+	NoIncrementalBackfill bool
+
+	// WebhookStatus is returned by setup-webhook ("created" by default).
+	// A non-zero WebhookError makes it fail with that status code.
+	WebhookStatus string
+	WebhookError  int
+
+	// Brief is returned verbatim by /api/brief.
+	Brief json.RawMessage
+
+	// MinVersion, when set, is advertised in X-Codag-Min-Version and
+	// requests from older clients are rejected with 426.
+	MinVersion string
+
+	repos       []Repo
+	nextRepoID  int
+	sessions    []Session
+	apiTokens   []apiToken
+	nextTokenID int
+	refreshes   int
+	requests    []Request
+	overrides   map[string]http.HandlerFunc
+	authCodes   map[string]authCode
+	statsServed map[int]int
+}
+
+// New starts a fake server that is closed when the test ends.
+func New(t testing.TB) *Server {
+	s := &Server{
+		AccessToken:    "access-1",
+		RefreshToken:   "refresh-1",
+		Login:          "octocat",
+		BackfillStatus: "started",
+		WebhookStatus:  "created",
+		Stats:          map[int][]Stats{},
+		Brief:          json.RawMessage(`{"signals":[]}`),
+		nextRepoID:     1,
+		nextTokenID:    1,
+		overrides:      map[string]http.HandlerFunc{},
+		authCodes:      map[string]authCode{},
+		statsServed:    map[int]int{},
+		sessions: []Session{
+			{ID: "sess-1", Device: "test-machine", Client: "codag-cli", CreatedAt: "2026-01-01T00:00:00Z"},
+		},
+	}
+	s.mux = http.NewServeMux()
+	s.routes()
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Handle overrides the handler for a route pattern such as
+// "POST /api/repos/{id}/backfill". Overrides take precedence over the
+// built-in endpoints.
+func (s *Server) Handle(pattern string, h http.HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.overrides[pattern] = h
+}
+
+// AddRepo registers a repo directly and returns it.
+func (s *Server) AddRepo(owner, name string) Repo {
+	return s.AddRepoAt("github", "github.com", owner, name)
+}
+
+// AddRepoAt registers a repo on another provider or host. GitLab owners
+// may be nested groups ("group/sub").
+func (s *Server) AddRepoAt(provider, host, owner, name string) Repo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addRepoLocked(provider, host, owner, name)
+}
+
+// Repos returns the registered repos.
+func (s *Server) Repos() []Repo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Repo(nil), s.repos...)
+}
+
+// ExpireAccessToken invalidates the current access token so the next
+// authenticated request gets a 401 and the client has to refresh.
+func (s *Server) ExpireAccessToken() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.AccessToken = fmt.Sprintf("access-expired-%d", s.refreshes)
+}
+
+// IssueJWT replaces the access token with an unsigned JWT for login that
+// expires after ttl, and returns it.
+func (s *Server) IssueJWT(login string, ttl time.Duration) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.AccessToken = MakeJWT(map[string]interface{}{
+		"sub":          "user:1",
+		"github_login": login,
+		"scope":        "repos:read briefs:read",
+		"iat":          now.Unix(),
+		"exp":          now.Add(ttl).Unix(),
+	})
+	return s.AccessToken
+}
+
+// MakeJWT builds an unsigned JWT carrying claims.
+func MakeJWT(claims map[string]interface{}) string {
+	enc := base64.RawURLEncoding
+	payload, _ := json.Marshal(claims)
+	return enc.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`)) + "." + enc.EncodeToString(payload) + ".sig"
+}
+
+// Refreshes returns how many times tokens were refreshed.
+func (s *Server) Refreshes() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.refreshes
+}
+
+// Requests returns every request received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Called reports whether a request with the given method and path was made.
+func (s *Server) Called(method, path string) bool {
+	for _, r := range s.Requests() {
+		if r.Method == method && r.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.RawQuery,
+		Body:   string(body),
+		Auth:   r.Header.Get("Authorization"),
+
+		ClientVersion: r.Header.Get("X-Codag-Client-Version"),
+	})
+	minVersion := s.MinVersion
+	overrides := make(map[string]http.HandlerFunc, len(s.overrides))
+	for k, v := range s.overrides {
+		overrides[k] = v
+	}
+	s.mu.Unlock()
+
+	if minVersion != "" {
+		w.Header().Set("X-Codag-Min-Version", minVersion)
+		if version.IsNewer(minVersion, r.Header.Get("X-Codag-Client-Version")) {
+			writeJSON(w, http.StatusUpgradeRequired, map[string]string{"detail": "Please upgrade codag."})
+			return
+		}
+	}
+
+	if len(overrides) > 0 {
+		mux := http.NewServeMux()
+		for pattern, h := range overrides {
+			mux.HandleFunc(pattern, h)
+		}
+		if _, pattern := mux.Handler(r); pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) routes() {
+	s.mux.HandleFunc("GET /api/health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, 200, map[string]string{"status": "ok"})
+	})
+
+	s.mux.HandleFunc("POST /api/auth/device", s.handleDevice)
+	s.mux.HandleFunc("POST /api/auth/device/token", s.handleDeviceToken)
+	s.mux.HandleFunc("GET /api/auth/authorize", s.handleAuthorize)
+	s.mux.HandleFunc("POST /api/auth/token", s.handleToken)
+	s.mux.HandleFunc("POST /api/auth/refresh", s.handleRefresh)
+	s.mux.HandleFunc("POST /api/auth/logout", s.handleLogout)
+	s.mux.HandleFunc("GET /api/auth/sessions", s.authed(s.handleListSessions))
+	s.mux.HandleFunc("DELETE /api/auth/sessions/{id}", s.authed(s.handleRevokeSession))
+	s.mux.HandleFunc("POST /api/auth/sessions/revoke-all", s.authed(s.handleRevokeAllSessions))
+
+	s.mux.HandleFunc("GET /api/repos", s.authed(s.handleListRepos))
+	s.mux.HandleFunc("POST /api/repos", s.authed(s.handleRegisterRepo))
+	s.mux.HandleFunc("GET /api/repos/resolve", s.authed(s.handleResolve))
+	s.mux.HandleFunc("GET /api/repos/{id}", s.authed(s.handleGetRepo))
+	s.mux.HandleFunc("DELETE /api/repos/{id}", s.authed(s.handleDeleteRepo))
+	s.mux.HandleFunc("POST /api/repos/{id}/backfill", s.authed(s.handleBackfill))
+	s.mux.HandleFunc("POST /api/repos/{id}/backfill/incremental", s.authed(s.handleIncrementalBackfill))
+	s.mux.HandleFunc("POST /api/repos/{id}/backfill/cancel", s.authed(s.handleCancelBackfill))
+	s.mux.HandleFunc("POST /api/repos/{id}/setup-webhook", s.authed(s.handleWebhook))
+	s.mux.HandleFunc("GET /api/stats", s.authed(s.handleStats))
+	s.mux.HandleFunc("POST /api/stats/bulk", s.authed(s.handleStatsBulk))
+	s.mux.HandleFunc("POST /api/brief", s.authed(s.handleBrief))
+	s.mux.HandleFunc("GET /api/console/me", s.authed(s.handleMe))
+
+	s.mux.HandleFunc("GET /api/auth/tokens", s.authed(s.handleListTokens))
+	s.mux.HandleFunc("POST /api/auth/tokens", s.authed(s.handleCreateToken))
+	s.mux.HandleFunc("DELETE /api/auth/tokens/{id}", s.authed(s.handleRevokeToken))
+}
+
+// authed rejects requests without the current access token.
+func (s *Server) authed(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		auth := r.Header.Get("Authorization")
+		valid := auth == "Bearer "+s.AccessToken
+		for _, t := range s.apiTokens {
+			if !t.revoked && auth == "Bearer "+t.secret {
+				valid = true
+			}
+		}
+		s.mu.Unlock()
+		if !valid {
+			writeJSON(w, 401, map[string]string{"detail": "Invalid or expired token"})
+			return
+		}
+		h(w, r)
+	}
+}
+
+func (s *Server) handleDevice(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		DeviceName string `json:"device_name"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	if req.DeviceName != "" {
+		s.mu.Lock()
+		s.sessions[0].Device = req.DeviceName
+		s.mu.Unlock()
+	}
+	writeJSON(w, 200, map[string]interface{}{
+		"device_code":               "device-code-1",
+		"user_code":                 "ABCD-1234",
+		"verification_uri":          s.URL + "/device",
+		"verification_uri_complete": s.URL + "/device?user_code=ABCD-1234",
+		"expires_in":                60,
+		"interval":                  0,
+	})
+}
+
+func (s *Server) handleDeviceToken(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.DeviceExpired {
+		writeJSON(w, 410, map[string]string{"detail": "expired"})
+		return
+	}
+	if s.DevicePending > 0 {
+		s.DevicePending--
+		writeJSON(w, 428, map[string]string{"detail": "authorization_pending"})
+		return
+	}
+	writeJSON(w, 200, map[string]interface{}{
+		"access_token":  s.AccessToken,
+		"refresh_token": s.RefreshToken,
+		"user":          map[string]string{"github_login": s.Login},
+		"subscription":  map[string]string{"tier": "pro", "status": "active"},
+	})
+}
+
+// authCode is an issued authorization code and the PKCE challenge and
+// redirect URI it was issued for.
+type authCode struct {
+	challenge   string
+	redirectURI string
+}
+
+func (s *Server) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	missing := s.NoAuthorize
+	s.mu.Unlock()
+	if missing {
+		writeJSON(w, 404, map[string]string{"detail": "Not Found"})
+		return
+	}
+
+	q := r.URL.Query()
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || redirect.Scheme != "http" || redirect.Hostname() != "127.0.0.1" {
+		writeJSON(w, 400, map[string]string{"detail": "redirect_uri must be a loopback address"})
+		return
+	}
+	if q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		writeJSON(w, 400, map[string]string{"detail": "PKCE with S256 is required"})
+		return
+	}
+
+	back := redirect.Query()
+	back.Set("state", q.Get("state"))
+
+	s.mu.Lock()
+	if s.AuthorizeDenied {
+		back.Set("error", "access_denied")
+	} else {
+		code := fmt.Sprintf("auth-code-%d", len(s.authCodes)+1)
+		s.authCodes[code] = authCode{challenge: q.Get("code_challenge"), redirectURI: redirect.String()}
+		back.Set("code", code)
+		if name := q.Get("device_name"); name != "" {
+			s.sessions[0].Device = name
+		}
+	}
+	s.mu.Unlock()
+
+	redirect.RawQuery = back.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		GrantType    string `json:"grant_type"`
+		Code         string `json:"code"`
+		CodeVerifier string `json:"code_verifier"`
+		RedirectURI  string `json:"redirect_uri"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	issued, ok := s.authCodes[req.Code]
+	delete(s.authCodes, req.Code) // codes are single-use
+	sum := sha256.Sum256([]byte(req.CodeVerifier))
+	if req.GrantType != "authorization_code" || !ok || req.RedirectURI != issued.redirectURI ||
+		base64.RawURLEncoding.EncodeToString(sum[:]) != issued.challenge {
+		writeJSON(w, 400, map[string]string{"detail": "invalid_grant"})
+		return
+	}
+	writeJSON(w, 200, map[string]interface{}{
+		"access_token":  s.AccessToken,
+		"refresh_token": s.RefreshToken,
+		"user":          map[string]string{"github_login": s.Login},
+		"subscription":  map[string]string{"tier": "pro", "status": "active"},
+	})
+}
+
+func (s *Server) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if req.RefreshToken != s.RefreshToken {
+		writeJSON(w, 401, map[string]string{"detail": "Invalid refresh token"})
+		return
+	}
+	s.refreshes++
+	s.AccessToken = fmt.Sprintf("access-%d", s.refreshes+1)
+	s.RefreshToken = fmt.Sprintf("refresh-%d", s.refreshes+1)
+	writeJSON(w, 200, map[string]string{
+		"access_token":  s.AccessToken,
+		"refresh_token": s.RefreshToken,
+	})
+}
+
+func (s *Server) handleListRepos(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	repos := append([]Repo(nil), s.repos...)
+	pageSize, legacy := s.PageSize, s.LegacyRepoList
+	s.mu.Unlock()
+
+	if legacy {
+		writeJSON(w, 200, repos)
+		return
+	}
+
+	q := r.URL.Query()
+	if owner := q.Get("owner"); owner != "" {
+		filtered := repos[:0]
+		for _, repo := range repos {
+			if strings.EqualFold(repo.Owner, owner) {
+				filtered = append(filtered, repo)
+			}
+		}
+		repos = filtered
+	}
+	if filter := strings.ToLower(q.Get("q")); filter != "" {
+		filtered := repos[:0]
+		for _, repo := range repos {
+			full := strings.ToLower(repo.Owner + "/" + repo.Name)
+			byFull, _ := path.Match(filter, full)
+			byName, _ := path.Match(filter, strings.ToLower(repo.Name))
+			if byFull || byName || strings.Contains(full, filter) {
+				filtered = append(filtered, repo)
+			}
+		}
+		repos = filtered
+	}
+	if q.Get("sort") == "-created_at" {
+		for i, j := 0, len(repos)-1; i < j; i, j = i+1, j-1 {
+			repos[i], repos[j] = repos[j], repos[i]
+		}
+	}
+	if limit, _ := strconv.Atoi(q.Get("limit")); limit > 0 && (pageSize == 0 || limit < pageSize) {
+		pageSize = limit
+	}
+
+	start, _ := strconv.Atoi(q.Get("cursor"))
+	if start > len(repos) {
+		start = len(repos)
+	}
+	end := len(repos)
+	next := ""
+	if pageSize > 0 && start+pageSize < len(repos) {
+		end = start + pageSize
+		next = strconv.Itoa(end)
+	}
+	writeJSON(w, 200, map[string]interface{}{
+		"repos":       repos[start:end],
+		"next_cursor": next,
+	})
+}
+
+func (s *Server) handleRegisterRepo(w http.ResponseWriter, r *http.Request) {
+	var req repoRef
+	json.NewDecoder(r.Body).Decode(&req)
+	provider, host, owner, name, ok := req.parse()
+	if !ok {
+		writeJSON(w, 422, map[string]string{"detail": "provider, host and path are required"})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if repo, ok := s.findRepoLocked(provider, host, owner+"/"+name); ok {
+		writeJSON(w, 200, repo)
+		return
+	}
+	writeJSON(w, 201, s.addRepoLocked(provider, host, owner, name))
+}
+
+// repoRef identifies a repo in requests: by provider, host and path, or
+// by github_url from clients that predate other providers.
+type repoRef struct {
+	Provider  string `json:"provider"`
+	Host      string `json:"host"`
+	Path      string `json:"path"`
+	GithubURL string `json:"github_url"`
+}
+
+func (ref repoRef) parse() (provider, host, owner, name string, ok bool) {
+	provider, host, p := ref.Provider, ref.Host, ref.Path
+	if provider == "" && ref.GithubURL != "" {
+		provider = "github"
+		rest := strings.TrimPrefix(strings.TrimPrefix(ref.GithubURL, "https://"), "http://")
+		host, p, _ = strings.Cut(rest, "/")
+	}
+	p = strings.TrimSuffix(strings.Trim(p, "/"), ".git")
+	i := strings.LastIndex(p, "/")
+	if provider == "" || host == "" || i <= 0 {
+		return "", "", "", "", false
+	}
+	return provider, strings.ToLower(host), p[:i], p[i+1:], true
+}
+
+func (s *Server) findRepoLocked(provider, host, path string) (Repo, bool) {
+	for _, repo := range s.repos {
+		if repo.Provider == provider && strings.EqualFold(repo.Host, host) &&
+			strings.EqualFold(repo.Owner+"/"+repo.Name, path) {
+			return repo, true
+		}
+	}
+	return Repo{}, false
+}
+
+func (s *Server) handleResolve(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	ref := repoRef{Provider: q.Get("provider"), Host: q.Get("host"), Path: q.Get("path"), GithubURL: q.Get("github_url")}
+	provider, host, owner, name, ok := ref.parse()
+	if !ok {
+		writeJSON(w, 422, map[string]string{"detail": "provider, host and path are required"})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if repo, ok := s.findRepoLocked(provider, host, owner+"/"+name); ok {
+		writeJSON(w, 200, repo)
+		return
+	}
+	writeJSON(w, 404, map[string]string{"detail": "Repo not registered"})
+}
+
+func (s *Server) handleGetRepo(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.repoID(w, r)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, repo := range s.repos {
+		if repo.ID == id {
+			writeJSON(w, 200, repo)
+			return
+		}
+	}
+	writeJSON(w, 404, map[string]string{"detail": "Repo not found"})
+}
+
+func (s *Server) handleDeleteRepo(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.repoID(w, r)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, repo := range s.repos {
+		if repo.ID == id {
+			s.repos = append(s.repos[:i], s.repos[i+1:]...)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	writeJSON(w, 404, map[string]string{"detail": "Repo not found"})
+}
+
+func (s *Server) handleBackfill(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.repoID(w, r)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	status := s.BackfillStatus
+	s.mu.Unlock()
+	writeJSON(w, 200, map[string]interface{}{
+		"repo_id": id,
+		"status":  status,
+		"message": "Backfill " + status,
+	})
+}
+
+func (s *Server) handleIncrementalBackfill(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	unsupported := s.NoIncrementalBackfill
+	s.mu.Unlock()
+	if unsupported {
+		writeJSON(w, 404, map[string]string{"detail": "Not Found"})
+		return
+	}
+	var req struct {
+		Since string `json:"since"`
+		Until string `json:"until"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, 422, map[string]string{"detail": "invalid body"})
+		return
+	}
+	for _, t := range []string{req.Since, req.Until} {
+		if _, err := time.Parse(time.RFC3339, t); t != "" && err != nil {
+			writeJSON(w, 422, map[string]string{"detail": "invalid date " + t})
+			return
+		}
+	}
+	s.handleBackfill(w, r)
+}
+
+func (s *Server) handleCancelBackfill(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.repoID(w, r)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	status := "not_running"
+	if seq := s.Stats[id]; len(seq) > 0 {
+		next := seq[min(s.statsServed[id], len(seq)-1)]
+		if next.Indexing {
+			next.Indexing, next.Cancelled = false, true
+			s.Stats[id] = []Stats{next}
+			s.statsServed[id] = 0
+			status = "cancelled"
+		}
+	}
+	s.mu.Unlock()
+	writeJSON(w, 200, map[string]interface{}{
+		"repo_id": id,
+		"status":  status,
+		"message": "Backfill " + status,
+	})
+}
+
+func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.repoID(w, r); !ok {
+		return
+	}
+	s.mu.Lock()
+	status, code := s.WebhookStatus, s.WebhookError
+	s.mu.Unlock()
+	if code != 0 {
+		writeJSON(w, code, map[string]string{"detail": "webhook setup failed"})
+		return
+	}
+	writeJSON(w, 200, map[string]interface{}{"status": status, "webhook_id": 1})
+}
+
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("repo"))
+	if err != nil {
+		writeJSON(w, 422, map[string]string{"detail": "repo is required"})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, 200, s.nextStatsLocked(id))
+}
+
+func (s *Server) handleStatsBulk(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RepoIDs []int `json:"repo_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, 422, map[string]string{"detail": "invalid body"})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.NoBulkStats {
+		writeJSON(w, 404, map[string]string{"detail": "Not Found"})
+		return
+	}
+	stats := []Stats{}
+	for _, id := range req.RepoIDs {
+		stats = append(stats, s.nextStatsLocked(id))
+	}
+	writeJSON(w, 200, map[string][]Stats{"stats": stats})
+}
+
+// nextStatsLocked returns the next entry of a repo's stats sequence.
+func (s *Server) nextStatsLocked(id int) Stats {
+	seq := s.Stats[id]
+	if len(seq) == 0 {
+		return Stats{RepoID: id}
+	}
+	i := s.statsServed[id]
+	if i >= len(seq) {
+		i = len(seq) - 1
+	}
+	s.statsServed[id]++
+	st := seq[i]
+	st.RepoID = id
+	return st
+}
+
+func (s *Server) handleBrief(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Repo  int      `json:"repo"`
+		Files []string `json:"files"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Files) == 0 {
+		writeJSON(w, 422, map[string]string{"detail": "files are required"})
+		return
+	}
+	s.mu.Lock()
+	brief := s.Brief
+	s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(brief)
+}
+
+func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, 200, map[string]interface{}{
+		"user": map[string]string{
+			"github_login": s.Login,
+			"email":        s.Login + "@example.com",
+		},
+		"subscription": map[string]interface{}{"tier": "pro", "status": "active"},
+		"repos":        s.repos,
+		"orgs":         []interface{}{},
+	})
+}
+
+// apiToken is a long-lived token created through /api/auth/tokens.
+type apiToken struct {
+	ID        int     `json:"id"`
+	Name      string  `json:"name"`
+	Kind      string  `json:"kind"`
+	Org       string  `json:"org,omitempty"`
+	Prefix    string  `json:"prefix"`
+	CreatedAt string  `json:"created_at"`
+	ExpiresAt *string `json:"expires_at"`
+
+	secret  string
+	revoked bool
+}
+
+// CreateAPIToken creates a long-lived token directly and returns its secret.
+func (s *Server) CreateAPIToken(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.createTokenLocked(name, "").secret
+}
+
+func (s *Server) createTokenLocked(name, org string) apiToken {
+	kind := "personal"
+	if org != "" {
+		kind = "service_account"
+	}
+	secret := fmt.Sprintf("codag_pat_%d", s.nextTokenID)
+	t := apiToken{
+		ID:        s.nextTokenID,
+		Name:      name,
+		Kind:      kind,
+		Org:       org,
+		Prefix:    secret[:10],
+		CreatedAt: "2026-01-01T00:00:00Z",
+		secret:    secret,
+	}
+	s.nextTokenID++
+	s.apiTokens = append(s.apiTokens, t)
+	return t
+}
+
+func (s *Server) handleListTokens(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens := []apiToken{}
+	for _, t := range s.apiTokens {
+		if !t.revoked {
+			tokens = append(tokens, t)
+		}
+	}
+	writeJSON(w, 200, tokens)
+}
+
+func (s *Server) handleCreateToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name          string `json:"name"`
+		Org           string `json:"org"`
+		ExpiresInDays int    `json:"expires_in_days"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
+		writeJSON(w, 422, map[string]string{"detail": "name is required"})
+		return
+	}
+	s.mu.Lock()
+	t := s.createTokenLocked(req.Name, req.Org)
+	s.mu.Unlock()
+	writeJSON(w, 201, struct {
+		apiToken
+		Token string `json:"token"`
+	}{t, t.secret})
+}
+
+func (s *Server) handleRevokeToken(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(r.PathValue("id"))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.apiTokens {
+		if s.apiTokens[i].ID == id && !s.apiTokens[i].revoked {
+			s.apiTokens[i].revoked = true
+			w.WriteHeader(204)
+			return
+		}
+	}
+	writeJSON(w, 404, map[string]string{"detail": "Token not found"})
+}
+
+// Session is a signed-in device listed by /api/auth/sessions. The first
+// session owns the server's AccessToken/RefreshToken; revoking it
+// invalidates them.
+type Session struct {
+	ID         string  `json:"id"`
+	Device     string  `json:"device"`
+	Client     string  `json:"client"`
+	CreatedAt  string  `json:"created_at"`
+	LastUsedAt *string `json:"last_used_at"`
+	Current    bool    `json:"current"`
+
+	revoked bool
+}
+
+// AddSession adds a session for another device.
+func (s *Server) AddSession(device string) Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess := Session{
+		ID:        fmt.Sprintf("sess-%d", len(s.sessions)+1),
+		Device:    device,
+		Client:    "codag-cli",
+		CreatedAt: "2026-02-01T00:00:00Z",
+	}
+	s.sessions = append(s.sessions, sess)
+	return sess
+}
+
+// ActiveSessions returns the IDs of sessions that haven't been revoked.
+func (s *Server) ActiveSessions() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ids []string
+	for _, sess := range s.sessions {
+		if !sess.revoked {
+			ids = append(ids, sess.ID)
+		}
+	}
+	return ids
+}
+
+// Device returns the device name of the session the current tokens belong to.
+func (s *Server) Device() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions[0].Device
+}
+
+// revokeSessionLocked revokes sessions[i], invalidating the server's
+// tokens if it's the current session.
+func (s *Server) revokeSessionLocked(i int) {
+	s.sessions[i].revoked = true
+	if i == 0 {
+		s.AccessToken = "access-revoked"
+		s.RefreshToken = "refresh-revoked"
+	}
+}
+
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if req.RefreshToken != s.RefreshToken {
+		writeJSON(w, 401, map[string]string{"detail": "Invalid refresh token"})
+		return
+	}
+	s.revokeSessionLocked(0)
+	writeJSON(w, 200, map[string]string{"status": "ok"})
+}
+
+func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := []Session{}
+	for i, sess := range s.sessions {
+		if !sess.revoked {
+			sess.Current = i == 0
+			list = append(list, sess)
+		}
+	}
+	writeJSON(w, 200, list)
+}
+
+func (s *Server) handleRevokeSession(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.sessions {
+		if s.sessions[i].ID == id && !s.sessions[i].revoked {
+			s.revokeSessionLocked(i)
+			w.WriteHeader(204)
+			return
+		}
+	}
+	writeJSON(w, 404, map[string]string{"detail": "Session not found"})
+}
+
+func (s *Server) handleRevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for i := range s.sessions {
+		if !s.sessions[i].revoked {
+			s.revokeSessionLocked(i)
+			n++
+		}
+	}
+	writeJSON(w, 200, map[string]int{"revoked": n})
+}
+
+// repoID parses the {id} path value and checks the repo exists.
+func (s *Server) repoID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, repo := range s.repos {
+			if repo.ID == id {
+				return id, true
+			}
+		}
+	}
+	writeJSON(w, 404, map[string]string{"detail": "Repo not found"})
+	return 0, false
+}
+
+func (s *Server) addRepoLocked(provider, host, owner, name string) Repo {
+	webURL := "https://" + host + "/" + owner + "/" + name
+	repo := Repo{
+		ID:       s.nextRepoID,
+		Owner:    owner,
+		Name:     name,
+		Provider: provider,
+		Host:     host,
+		URL:      webURL,
+	}
+	if provider == "github" {
+		repo.GithubURL = webURL
+	}
+	s.nextRepoID++
+	s.repos = append(s.repos, repo)
+	return repo
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
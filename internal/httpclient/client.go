@@ -0,0 +1,45 @@
+// Package httpclient builds the HTTP clients used to talk to the Codag API
+// and GitHub, so that proxy, TLS and tracing settings apply everywhere.
+package httpclient
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// clientVersion is the CLI version, sent on every request and reported in
+// HAR files.
+var clientVersion = "dev"
+
+// SetVersion records the running CLI version.
+func SetVersion(v string) {
+	clientVersion = v
+}
+
+// New returns an http.Client with the given timeout that uses the shared,
+// configurable transport. A zero timeout means no timeout.
+func New(timeout time.Duration) *http.Client {
+	return newClient(timeout, "")
+}
+
+// NewForServer is New for talking to the Codag server at serverURL.
+// Requests to its host also negotiate the client version; other hosts,
+// e.g. after a redirect, don't.
+func NewForServer(serverURL string, timeout time.Duration) *http.Client {
+	host := ""
+	if u, err := url.Parse(serverURL); err == nil {
+		host = u.Host
+	}
+	return newClient(timeout, host)
+}
+
+func newClient(timeout time.Duration, serverHost string) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &versionTransport{
+			next:       &tracingTransport{base: configuredTransport{}},
+			serverHost: serverHost,
+		},
+	}
+}
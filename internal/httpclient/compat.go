@@ -0,0 +1,83 @@
+package httpclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"strings"
+
+	"github.com/codag-megalith/codag-cli/internal/version"
+)
+
+// Headers used for client/server version negotiation.
+const (
+	// HeaderClientVersion carries the CLI version on requests to the server.
+	HeaderClientVersion = "X-Codag-Client-Version"
+	// HeaderMinVersion is set by the server to the oldest CLI version it
+	// still supports.
+	HeaderMinVersion = "X-Codag-Min-Version"
+)
+
+// UpgradeRequiredError is returned when the server rejects this CLI
+// version, either with 426 Upgrade Required or by declaring a minimum
+// version newer than ours.
+type UpgradeRequiredError struct {
+	Current    string
+	MinVersion string // empty if the server didn't say
+	Detail     string
+}
+
+func (e *UpgradeRequiredError) Error() string {
+	if e.MinVersion != "" {
+		return fmt.Sprintf("codag %s is no longer supported by the server (requires %s or newer)", e.Current, e.MinVersion)
+	}
+	return fmt.Sprintf("codag %s is no longer supported by the server", e.Current)
+}
+
+// UserAgent returns the User-Agent sent on every request.
+func UserAgent() string {
+	return fmt.Sprintf("codag-cli/%s (%s; %s)", clientVersion, runtime.GOOS, runtime.GOARCH)
+}
+
+// versionTransport identifies the CLI on every request. Requests to the
+// Codag server also carry the client version, and the version requirements
+// it declares are turned into UpgradeRequiredError.
+type versionTransport struct {
+	next       http.RoundTripper
+	serverHost string // empty if this client doesn't talk to the Codag server
+}
+
+func (t *versionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", UserAgent())
+	}
+	toServer := t.serverHost != "" && strings.EqualFold(req.URL.Host, t.serverHost)
+	if toServer {
+		req.Header.Set(HeaderClientVersion, clientVersion)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || !toServer {
+		return resp, err
+	}
+
+	minVersion := resp.Header.Get(HeaderMinVersion)
+	tooOld := version.IsRelease(clientVersion) && minVersion != "" && version.IsNewer(minVersion, clientVersion)
+	if resp.StatusCode != http.StatusUpgradeRequired && !tooOld {
+		return resp, nil
+	}
+
+	upgradeErr := &UpgradeRequiredError{Current: clientVersion, MinVersion: minVersion}
+	var body struct {
+		Detail string `json:"detail"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+	if json.Unmarshal(data, &body) == nil {
+		upgradeErr.Detail = body.Detail
+	}
+	return nil, upgradeErr
+}
@@ -0,0 +1,120 @@
+package httpclient
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func withVersion(t *testing.T, v string) {
+	t.Helper()
+	old := clientVersion
+	clientVersion = v
+	t.Cleanup(func() { clientVersion = old })
+}
+
+func TestVersionHeadersSent(t *testing.T) {
+	withVersion(t, "1.2.3")
+	var ua, cv string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ua, cv = r.Header.Get("User-Agent"), r.Header.Get(HeaderClientVersion)
+	}))
+	defer srv.Close()
+
+	resp, err := NewForServer(srv.URL, 0).Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if !strings.HasPrefix(ua, "codag-cli/1.2.3 ") {
+		t.Fatalf("unexpected User-Agent %q", ua)
+	}
+	if cv != "1.2.3" {
+		t.Fatalf("unexpected %s %q", HeaderClientVersion, cv)
+	}
+}
+
+func TestUpgradeRequiredStatus(t *testing.T) {
+	withVersion(t, "1.2.3")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUpgradeRequired)
+		w.Write([]byte(`{"detail":"brief v2 required"}`))
+	}))
+	defer srv.Close()
+
+	_, err := NewForServer(srv.URL, 0).Get(srv.URL)
+	var upgradeErr *UpgradeRequiredError
+	if !errors.As(err, &upgradeErr) {
+		t.Fatalf("expected UpgradeRequiredError, got %v", err)
+	}
+	if upgradeErr.Detail != "brief v2 required" || upgradeErr.Current != "1.2.3" {
+		t.Fatalf("unexpected error fields: %+v", upgradeErr)
+	}
+}
+
+func TestMinVersionHeader(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HeaderMinVersion, "1.3.0")
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		version  string
+		rejected bool
+	}{
+		{"1.2.3", true},
+		{"v1.3.0", false},
+		{"1.10.0", false},
+		{"dev", false}, // dev builds only honour an explicit 426
+	}
+	for _, tt := range tests {
+		withVersion(t, tt.version)
+		resp, err := NewForServer(srv.URL, 0).Get(srv.URL)
+		var upgradeErr *UpgradeRequiredError
+		if got := errors.As(err, &upgradeErr); got != tt.rejected {
+			t.Fatalf("version %s: rejected=%v, want %v (err=%v)", tt.version, got, tt.rejected, err)
+		}
+		if err == nil {
+			resp.Body.Close()
+		} else if upgradeErr.MinVersion != "1.3.0" {
+			t.Fatalf("expected MinVersion 1.3.0, got %q", upgradeErr.MinVersion)
+		}
+	}
+}
+
+func TestVersionNegotiationOnlyWithServer(t *testing.T) {
+	withVersion(t, "1.2.3")
+	var cv string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cv = r.Header.Get(HeaderClientVersion)
+		w.Header().Set(HeaderMinVersion, "9.0.0")
+		w.WriteHeader(http.StatusUpgradeRequired)
+	}))
+	defer other.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other.URL, http.StatusFound)
+	}))
+	defer server.Close()
+
+	for _, tc := range []struct {
+		name   string
+		client *http.Client
+		url    string
+	}{
+		{"plain client", New(0), other.URL},
+		{"redirect off the server", NewForServer(server.URL, 0), server.URL},
+	} {
+		cv = ""
+		resp, err := tc.client.Get(tc.url)
+		if err != nil {
+			t.Fatalf("%s: expected another host's response to pass through, got %v", tc.name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUpgradeRequired || cv != "" {
+			t.Fatalf("%s: expected 426 without a version header, got %d and %q", tc.name, resp.StatusCode, cv)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxLoggedBody is how much of a request or response body is written to
+// the debug log. HAR files keep the full (redacted) body.
+const maxLoggedBody = 2048
+
+const redacted = "[REDACTED]"
+
+var (
+	traceMu  sync.Mutex
+	debugOut io.Writer
+	harPath  string
+	harOut   *harWriter
+)
+
+// EnableDebug logs every request and response to w.
+func EnableDebug(w io.Writer) {
+	traceMu.Lock()
+	defer traceMu.Unlock()
+	debugOut = w
+}
+
+// EnableHAR records every request and response to a HAR file at path.
+// Entries are appended as requests finish, so the file survives
+// interrupts.
+func EnableHAR(path string) {
+	traceMu.Lock()
+	defer traceMu.Unlock()
+	harPath = path
+	harOut.close()
+	harOut = nil
+}
+
+func tracing() (io.Writer, string) {
+	traceMu.Lock()
+	defer traceMu.Unlock()
+	return debugOut, harPath
+}
+
+// tracingTransport logs and records requests when debugging is enabled.
+type tracingTransport struct {
+	base http.RoundTripper
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	out, har := tracing()
+	if out == nil && har == "" {
+		return t.base.RoundTrip(req)
+	}
+
+	reqBody, err := snapshotRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if out != nil {
+		fmt.Fprintf(out, "[debug] → %s %s\n", req.Method, redactURL(req.URL))
+		for _, h := range harHeaders(redactHeaders(req.Header)) {
+			fmt.Fprintf(out, "[debug]   %s: %s\n", h.Name, h.Value)
+		}
+		if len(reqBody) > 0 {
+			fmt.Fprintf(out, "[debug]   %s\n", truncate(redactBody(reqBody)))
+		}
+	}
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	elapsed := time.Since(start)
+
+	if err != nil {
+		if out != nil {
+			fmt.Fprintf(out, "[debug] ✗ %s %s failed after %s: %s\n",
+				req.Method, redactURL(req.URL), elapsed.Round(time.Millisecond), err)
+		}
+		if har != "" {
+			recordHAR(har, req, reqBody, nil, nil, start, elapsed)
+		}
+		return nil, err
+	}
+
+	var respBody []byte
+	if isTextual(resp.Header.Get("Content-Type")) {
+		respBody, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	}
+
+	if out != nil {
+		fmt.Fprintf(out, "[debug] ← %d %s (%s)\n",
+			resp.StatusCode, redactURL(req.URL), elapsed.Round(time.Millisecond))
+		switch {
+		case len(respBody) > 0:
+			fmt.Fprintf(out, "[debug]   %s\n", truncate(redactBody(respBody)))
+		case resp.ContentLength > 0:
+			fmt.Fprintf(out, "[debug]   (%s, %d bytes)\n", resp.Header.Get("Content-Type"), resp.ContentLength)
+		}
+	}
+	if har != "" {
+		recordHAR(har, req, reqBody, resp, respBody, start, elapsed)
+	}
+
+	return resp, nil
+}
+
+// snapshotRequestBody reads the request body and replaces it so the
+// request can still be sent.
+func snapshotRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+func isTextual(contentType string) bool {
+	ct := strings.ToLower(contentType)
+	return ct == "" ||
+		strings.HasPrefix(ct, "text/") ||
+		strings.Contains(ct, "json") ||
+		strings.Contains(ct, "xml") ||
+		strings.Contains(ct, "x-www-form-urlencoded")
+}
+
+func truncate(s string) string {
+	if len(s) > maxLoggedBody {
+		return s[:maxLoggedBody] + fmt.Sprintf("… (%d bytes total)", len(s))
+	}
+	return s
+}
+
+// secretKeys are JSON fields and query parameters whose values are never
+// logged.
+var secretKeys = map[string]bool{
+	"access_token":  true,
+	"refresh_token": true,
+	"device_code":   true,
+	"token":         true,
+	"code":          true,
+	"code_verifier": true,
+	"client_secret": true,
+	"password":      true,
+	"secret":        true,
+}
+
+// secretHeaders are headers whose values are never logged.
+var secretHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+var secretFieldRe = regexp.MustCompile(`("(?:access_token|refresh_token|device_code|token|code|code_verifier|client_secret|password|secret)"\s*:\s*)"[^"]*"`)
+
+// redactBody masks secret fields in a JSON body. Bodies that don't parse
+// as JSON are masked with a pattern match instead.
+func redactBody(body []byte) string {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return secretFieldRe.ReplaceAllString(string(body), `$1"`+redacted+`"`)
+	}
+	out, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return string(body)
+	}
+	return string(out)
+}
+
+func redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, child := range val {
+			if _, isString := child.(string); isString && secretKeys[strings.ToLower(k)] {
+				val[k] = redacted
+			} else {
+				val[k] = redactValue(child)
+			}
+		}
+	case []interface{}:
+		for i, child := range val {
+			val[i] = redactValue(child)
+		}
+	}
+	return v
+}
+
+// redactURL masks secret query parameters and any userinfo in a URL.
+func redactURL(u *url.URL) string {
+	c := *u
+	if c.User != nil {
+		c.User = url.User(redacted)
+	}
+	q := c.Query()
+	changed := false
+	for k := range q {
+		if secretKeys[strings.ToLower(k)] {
+			q.Set(k, redacted)
+			changed = true
+		}
+	}
+	if changed {
+		c.RawQuery = q.Encode()
+	}
+	return c.String()
+}
+
+// redactHeaders returns a copy of h with secret header values masked.
+func redactHeaders(h http.Header) http.Header {
+	out := make(http.Header, len(h))
+	for k, vs := range h {
+		if secretHeaders[http.CanonicalHeaderKey(k)] {
+			out[k] = []string{redacted}
+			continue
+		}
+		out[k] = append([]string(nil), vs...)
+	}
+	return out
+}
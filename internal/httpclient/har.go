@@ -0,0 +1,232 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HAR 1.2 structures — only the fields browsers' importers require.
+// See http://www.softwareishard.com/blog/har-12-spec/.
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// recordHAR appends an entry to the HAR file. resp is nil when the request
+// failed before a response arrived.
+func recordHAR(path string, req *http.Request, reqBody []byte, resp *http.Response, respBody []byte, start time.Time, elapsed time.Duration) {
+	ms := float64(elapsed.Microseconds()) / 1000
+
+	entry := harEntry{
+		StartedDateTime: start.Format(time.RFC3339Nano),
+		Time:            ms,
+		Request: harRequest{
+			Method:      req.Method,
+			URL:         redactURL(req.URL),
+			HTTPVersion: req.Proto,
+			Headers:     harHeaders(redactHeaders(req.Header)),
+			QueryString: []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    len(reqBody),
+		},
+		Response: harResponse{
+			Headers:     []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		Timings: harTimings{Wait: ms},
+	}
+	if entry.Request.HTTPVersion == "" {
+		entry.Request.HTTPVersion = "HTTP/1.1"
+	}
+	for k, vs := range req.URL.Query() {
+		for _, v := range vs {
+			if secretKeys[strings.ToLower(k)] {
+				v = redacted
+			}
+			entry.Request.QueryString = append(entry.Request.QueryString, harNameValue{Name: k, Value: v})
+		}
+	}
+	if len(reqBody) > 0 {
+		entry.Request.PostData = &harPostData{
+			MimeType: req.Header.Get("Content-Type"),
+			Text:     redactBody(reqBody),
+		}
+	}
+
+	if resp != nil {
+		entry.Response.Status = resp.StatusCode
+		entry.Response.StatusText = http.StatusText(resp.StatusCode)
+		entry.Response.HTTPVersion = resp.Proto
+		entry.Response.Headers = harHeaders(redactHeaders(resp.Header))
+		entry.Response.Content = harContent{
+			Size:     int(resp.ContentLength),
+			MimeType: resp.Header.Get("Content-Type"),
+		}
+		if respBody != nil {
+			entry.Response.Content.Size = len(respBody)
+			entry.Response.Content.Text = redactBody(respBody)
+			entry.Response.BodySize = len(respBody)
+		}
+	} else {
+		entry.Comment = "request failed before a response was received"
+	}
+
+	data, err := json.MarshalIndent(entry, "      ", "  ")
+	if err != nil {
+		return
+	}
+
+	traceMu.Lock()
+	defer traceMu.Unlock()
+	if harOut == nil || harOut.path != path {
+		harOut.close()
+		harOut = &harWriter{path: path}
+	}
+	if err := harOut.append(data); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: could not write HAR file: %s\n", err)
+	}
+}
+
+// harMaxEntries caps a HAR file. Once full it's moved to path + ".1",
+// replacing an older one, and a new file is started, so long sessions
+// such as `mcp serve` keep only their most recent traffic. A variable so
+// tests can lower it.
+var harMaxEntries = 1000
+
+// harLogEnd closes the entries list and the document.
+const harLogEnd = "\n    ]\n  }\n}\n"
+
+// harWriter appends entries to a HAR file without holding them in memory.
+// The file stays a complete document: each entry is written over the
+// closing brackets, which are then written again after it.
+type harWriter struct {
+	path    string
+	f       *os.File
+	end     int64 // offset of harLogEnd
+	entries int
+}
+
+func (w *harWriter) append(entry []byte) error {
+	if w.f != nil && w.entries >= harMaxEntries {
+		w.close()
+		if err := os.Rename(w.path, w.path+".1"); err != nil {
+			return err
+		}
+	}
+	if w.f == nil {
+		if err := w.start(); err != nil {
+			return err
+		}
+	}
+
+	var buf bytes.Buffer
+	if w.entries > 0 {
+		buf.WriteString(",")
+	}
+	buf.WriteString("\n      ")
+	buf.Write(entry)
+	n := int64(buf.Len())
+	buf.WriteString(harLogEnd)
+	if _, err := w.f.WriteAt(buf.Bytes(), w.end); err != nil {
+		return err
+	}
+	w.end += n
+	w.entries++
+	return nil
+}
+
+// start creates the file with an empty entries list.
+func (w *harWriter) start() error {
+	f, err := os.OpenFile(w.path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	creator, _ := json.Marshal(harCreator{Name: "codag", Version: clientVersion})
+	head := fmt.Sprintf("{\n  \"log\": {\n    \"version\": \"1.2\",\n    \"creator\": %s,\n    \"entries\": [", creator)
+	if _, err := f.WriteString(head + harLogEnd); err != nil {
+		f.Close()
+		return err
+	}
+	w.f, w.end, w.entries = f, int64(len(head)), 0
+	return nil
+}
+
+func (w *harWriter) close() {
+	if w != nil && w.f != nil {
+		w.f.Close()
+		w.f = nil
+	}
+}
+
+func harHeaders(h http.Header) []harNameValue {
+	out := []harNameValue{}
+	for k, vs := range h {
+		for _, v := range vs {
+			out = append(out, harNameValue{Name: k, Value: v})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
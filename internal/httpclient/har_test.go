@@ -0,0 +1,81 @@
+package httpclient
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func recordRequests(t *testing.T, path string, n int) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	EnableHAR(path)
+	t.Cleanup(func() { EnableHAR("") })
+	client := New(5 * time.Second)
+	for i := 0; i < n; i++ {
+		resp, err := client.Get(srv.URL + "/api/repos")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+}
+
+func readHAR(t *testing.T, path string) []harEntry {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file struct {
+		Log struct {
+			Version string     `json:"version"`
+			Creator harCreator `json:"creator"`
+			Entries []harEntry `json:"entries"`
+		} `json:"log"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatalf("%s is not valid JSON: %v\n%s", path, err, data)
+	}
+	if file.Log.Version != "1.2" || file.Log.Creator.Name != "codag" {
+		t.Fatalf("unexpected log header: %+v", file.Log)
+	}
+	return file.Log.Entries
+}
+
+func TestHARAppendsEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.har")
+	recordRequests(t, path, 3)
+
+	entries := readHAR(t, path)
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+	if entries[2].Response.Status != 200 {
+		t.Fatalf("unexpected response: %+v", entries[2].Response)
+	}
+}
+
+func TestHARRotatesWhenFull(t *testing.T) {
+	defer func(n int) { harMaxEntries = n }(harMaxEntries)
+	harMaxEntries = 2
+
+	path := filepath.Join(t.TempDir(), "trace.har")
+	recordRequests(t, path, 5)
+
+	if n := len(readHAR(t, path)); n != 1 {
+		t.Fatalf("current file has %d entries, want 1", n)
+	}
+	if n := len(readHAR(t, path+".1")); n != 2 {
+		t.Fatalf("rotated file has %d entries, want 2", n)
+	}
+}
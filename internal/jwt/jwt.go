@@ -0,0 +1,89 @@
+// Package jwt reads the claims of Codag access tokens locally. Signatures
+// are not verified — the server does that — so claims are only used for
+// display and to decide when to refresh.
+package jwt
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrNotJWT is returned for tokens that aren't JWTs, such as API tokens.
+var ErrNotJWT = errors.New("token is not a JWT")
+
+// Claims are the registered and Codag-specific claims we care about.
+type Claims struct {
+	Subject   string
+	Login     string
+	Scopes    []string
+	IssuedAt  time.Time
+	ExpiresAt time.Time // zero if the token doesn't expire
+}
+
+type rawClaims struct {
+	Sub         string      `json:"sub"`
+	GithubLogin string      `json:"github_login"`
+	Scope       string      `json:"scope"`
+	Scopes      []string    `json:"scopes"`
+	Iat         json.Number `json:"iat"`
+	Exp         json.Number `json:"exp"`
+}
+
+// Parse decodes a token's payload without verifying it.
+func Parse(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrNotJWT
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, ErrNotJWT
+	}
+
+	var raw rawClaims
+	dec := json.NewDecoder(strings.NewReader(string(payload)))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, ErrNotJWT
+	}
+
+	c := &Claims{
+		Subject:   raw.Sub,
+		Login:     raw.GithubLogin,
+		Scopes:    raw.Scopes,
+		IssuedAt:  unixTime(raw.Iat),
+		ExpiresAt: unixTime(raw.Exp),
+	}
+	if len(c.Scopes) == 0 && raw.Scope != "" {
+		c.Scopes = strings.Fields(raw.Scope)
+	}
+	return c, nil
+}
+
+// Expired reports whether the token has expired as of now.
+func (c *Claims) Expired(now time.Time) bool {
+	return !c.ExpiresAt.IsZero() && !now.Before(c.ExpiresAt)
+}
+
+// ExpiresWithin reports whether the token expires within d of now.
+func ExpiresWithin(token string, d time.Duration, now time.Time) bool {
+	c, err := Parse(token)
+	if err != nil || c.ExpiresAt.IsZero() {
+		return false
+	}
+	return c.ExpiresAt.Sub(now) < d
+}
+
+func unixTime(n json.Number) time.Time {
+	if n == "" {
+		return time.Time{}
+	}
+	f, err := n.Float64()
+	if err != nil || f <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(f), 0)
+}
@@ -0,0 +1,70 @@
+package jwt
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func makeToken(payload string) string {
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(`{"alg":"HS256"}`)) + "." + enc.EncodeToString([]byte(payload)) + ".sig"
+}
+
+func TestParse(t *testing.T) {
+	token := makeToken(`{"sub":"user:7","github_login":"octocat","scope":"repos:read briefs:read","iat":1700000000,"exp":1700003600}`)
+	c, err := Parse(token)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.Subject != "user:7" || c.Login != "octocat" {
+		t.Fatalf("unexpected subject/login: %+v", c)
+	}
+	if len(c.Scopes) != 2 || c.Scopes[1] != "briefs:read" {
+		t.Fatalf("unexpected scopes: %v", c.Scopes)
+	}
+	if !c.ExpiresAt.Equal(time.Unix(1700003600, 0)) {
+		t.Fatalf("unexpected exp: %v", c.ExpiresAt)
+	}
+	if !c.Expired(time.Unix(1700003600, 0)) || c.Expired(time.Unix(1700000000, 0)) {
+		t.Fatal("Expired disagrees with exp")
+	}
+}
+
+func TestParseScopesArray(t *testing.T) {
+	c, err := Parse(makeToken(`{"scopes":["a","b"]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(c.Scopes) != 2 || !c.ExpiresAt.IsZero() {
+		t.Fatalf("unexpected claims: %+v", c)
+	}
+}
+
+func TestParseNotJWT(t *testing.T) {
+	for _, token := range []string{"codag_pat_1", "a.b", "a.!!!.c", makeToken("not json")} {
+		if _, err := Parse(token); !errors.Is(err, ErrNotJWT) {
+			t.Fatalf("Parse(%q): expected ErrNotJWT, got %v", token, err)
+		}
+	}
+}
+
+func TestExpiresWithin(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	soon := makeToken(`{"exp":1700000060}`)
+	later := makeToken(`{"exp":1700003600}`)
+
+	if !ExpiresWithin(soon, 2*time.Minute, now) {
+		t.Fatal("token expiring in 1m should be within 2m")
+	}
+	if ExpiresWithin(later, 2*time.Minute, now) {
+		t.Fatal("token expiring in 1h should not be within 2m")
+	}
+	if ExpiresWithin("codag_pat_1", 2*time.Minute, now) {
+		t.Fatal("non-JWT tokens should never be treated as expiring")
+	}
+	if ExpiresWithin(makeToken(`{"sub":"x"}`), 2*time.Minute, now) {
+		t.Fatal("tokens without exp should never be treated as expiring")
+	}
+}
@@ -3,19 +3,25 @@ package mcp
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"os"
-	"os/exec"
-	"regexp"
 	"strings"
 	"time"
 
+	"github.com/codag-megalith/codag-cli/internal/api"
 	"github.com/codag-megalith/codag-cli/internal/config"
+	"github.com/codag-megalith/codag-cli/internal/httpclient"
+	"github.com/codag-megalith/codag-cli/internal/jwt"
+	"github.com/codag-megalith/codag-cli/internal/project"
+	"github.com/codag-megalith/codag-cli/internal/remote"
 )
 
-var sshRemoteRe = regexp.MustCompile(`^git@github\.com:(.+?)(?:\.git)?$`)
+// refreshLeeway is how long before expiry an access token is refreshed.
+// Matches api.RefreshLeeway.
+const refreshLeeway = 2 * time.Minute
 
 type Client struct {
 	baseURL       string
@@ -24,13 +30,19 @@ type Client struct {
 	repoID        int
 	available     bool
 	workspacePath string
+
+	// project holds .codag.yml settings: ignored paths and severity.
+	project *project.Config
+
+	// upgradeErr is set once the server has rejected this CLI version.
+	upgradeErr *httpclient.UpgradeRequiredError
 }
 
 type resolvedRepo struct {
-	ID       int    `json:"id"`
+	ID        int    `json:"id"`
 	GithubURL string `json:"github_url"`
-	Name     string `json:"name"`
-	Owner    string `json:"owner"`
+	Name      string `json:"name"`
+	Owner     string `json:"owner"`
 }
 
 func NewClient(baseURL, token, refreshToken, workspacePath string) *Client {
@@ -43,58 +55,101 @@ func NewClient(baseURL, token, refreshToken, workspacePath string) *Client {
 }
 
 func (c *Client) CheckAvailability() bool {
-	httpClient := &http.Client{Timeout: 3 * time.Second}
+	httpClient := httpclient.NewForServer(c.baseURL, 3*time.Second)
 
 	// 1. Health check
 	resp, err := httpClient.Get(c.baseURL + "/api/health")
 	if err != nil || resp.StatusCode != http.StatusOK {
+		c.noteUpgradeRequired(err)
 		return false
 	}
 	resp.Body.Close()
 
-	// 2. Detect git remote
-	githubURL := detectGitRemote(c.workspacePath)
-	if githubURL == "" {
+	// 2. Detect git remotes, preferring upstream in a fork
+	hosts := c.project.GitHosts().Merge(config.GetGitHosts())
+	candidates := remote.Candidates(c.workspacePath, c.project.PreferredRemotes(), hosts)
+
+	// 3. Resolve the repo ID of the first registered remote
+	httpClient.Timeout = 5 * time.Second
+	var repo resolvedRepo
+	_, registered, _ := remote.Choose(candidates, func(r remote.Repo) (bool, error) {
+		found, ok, err := c.resolve(httpClient, r)
+		if ok {
+			repo = found
+		}
+		return ok, err
+	})
+	if !registered {
 		return false
 	}
 
-	// 3. Resolve repo ID
+	c.repoID = repo.ID
+	c.available = true
+	return true
+}
+
+// resolve looks up a repo on the server. It reports false for a repo that
+// isn't registered.
+func (c *Client) resolve(httpClient *http.Client, target remote.Repo) (resolvedRepo, bool, error) {
 	resolveURL, _ := url.Parse(c.baseURL + "/api/repos/resolve")
 	q := resolveURL.Query()
-	q.Set("github_url", githubURL)
+	for k, v := range api.RepoRef(target) {
+		q.Set(k, v)
+	}
 	resolveURL.RawQuery = q.Encode()
 
 	req, _ := http.NewRequest("GET", resolveURL.String(), nil)
 	c.setHeaders(req)
 	req.Header.Set("Content-Type", "application/json")
 
-	httpClient.Timeout = 5 * time.Second
-	resp, err = httpClient.Do(req)
-	if err != nil || resp.StatusCode != http.StatusOK {
-		return false
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		c.noteUpgradeRequired(err)
+		return resolvedRepo{}, false, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return resolvedRepo{}, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resolvedRepo{}, false, fmt.Errorf("resolving repo: status %d", resp.StatusCode)
+	}
 
 	var repo resolvedRepo
 	if err := json.NewDecoder(resp.Body).Decode(&repo); err != nil {
-		return false
+		return resolvedRepo{}, false, err
 	}
-
-	c.repoID = repo.ID
-	c.available = true
-	return true
+	return repo, true, nil
 }
 
 func (c *Client) Brief(files []string) (json.RawMessage, error) {
+	if c.upgradeErr != nil {
+		return upgradeRequiredResponse(c.upgradeErr)
+	}
 	if !c.available {
 		return unavailableResponse()
 	}
+	files, ignored := c.project.FilterIgnored(files)
+	if len(files) == 0 {
+		return allIgnoredResponse(ignored)
+	}
 	body := map[string]interface{}{"repo": c.repoID, "files": files}
+	if c.project != nil && c.project.Severity.Min != "" {
+		body["min_severity"] = c.project.Severity.Min
+	}
 	return c.post("/api/brief", body)
 }
 
 func (c *Client) post(path string, body interface{}) (json.RawMessage, error) {
+	// Refresh ahead of expiry instead of waiting for a 401
+	if c.refreshToken != "" && jwt.ExpiresWithin(c.token, refreshLeeway, time.Now()) {
+		c.tryRefresh()
+	}
+
 	raw, statusCode, err := c.doPost(path, body)
+	if c.noteUpgradeRequired(err) {
+		return upgradeRequiredResponse(c.upgradeErr)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -102,6 +157,9 @@ func (c *Client) post(path string, body interface{}) (json.RawMessage, error) {
 	// Retry once on 401 with token refresh
 	if statusCode == http.StatusUnauthorized && c.tryRefresh() {
 		raw, statusCode, err = c.doPost(path, body)
+		if c.noteUpgradeRequired(err) {
+			return upgradeRequiredResponse(c.upgradeErr)
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -119,7 +177,7 @@ func (c *Client) doPost(path string, body interface{}) (json.RawMessage, int, er
 		return nil, 0, err
 	}
 
-	httpClient := &http.Client{Timeout: 10 * time.Second}
+	httpClient := httpclient.NewForServer(c.baseURL, 10*time.Second)
 	req, err := http.NewRequest("POST", c.baseURL+path, bytes.NewReader(data))
 	if err != nil {
 		return nil, 0, err
@@ -152,7 +210,7 @@ func (c *Client) tryRefresh() bool {
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := (&http.Client{Timeout: 5 * time.Second}).Do(req)
+	resp, err := httpclient.NewForServer(c.baseURL, 5*time.Second).Do(req)
 	if err != nil || resp.StatusCode != 200 {
 		return false
 	}
@@ -190,20 +248,38 @@ func unavailableResponse() (json.RawMessage, error) {
 	return data, nil
 }
 
-func detectGitRemote(workspacePath string) string {
-	cmd := exec.Command("git", "remote", "get-url", "origin")
-	cmd.Dir = workspacePath
-	out, err := cmd.Output()
-	if err != nil {
-		return ""
+func allIgnoredResponse(ignored []string) (json.RawMessage, error) {
+	msg := map[string]interface{}{
+		"signals": []interface{}{},
+		"ignored": ignored,
+		"message": "All requested files are ignored by .codag.yml.",
 	}
-	remote := strings.TrimSpace(string(out))
+	data, _ := json.Marshal(msg)
+	return data, nil
+}
+
+// noteUpgradeRequired records err if the server rejected this CLI version.
+func (c *Client) noteUpgradeRequired(err error) bool {
+	var upgradeErr *httpclient.UpgradeRequiredError
+	if errors.As(err, &upgradeErr) {
+		c.upgradeErr = upgradeErr
+		return true
+	}
+	return false
+}
 
-	if m := sshRemoteRe.FindStringSubmatch(remote); m != nil {
-		return "https://github.com/" + m[1]
+func upgradeRequiredResponse(err *httpclient.UpgradeRequiredError) (json.RawMessage, error) {
+	msg := map[string]string{
+		"error":           "codag_upgrade_required",
+		"message":         err.Error() + ". Run `codag upgrade` and restart your editor.",
+		"current_version": err.Current,
+	}
+	if err.MinVersion != "" {
+		msg["min_version"] = err.MinVersion
 	}
-	if strings.Contains(remote, "github.com") {
-		return strings.TrimSuffix(remote, ".git")
+	if err.Detail != "" {
+		msg["detail"] = err.Detail
 	}
-	return ""
+	data, _ := json.Marshal(msg)
+	return data, nil
 }
@@ -0,0 +1,229 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/codag-megalith/codag-cli/internal/config"
+	"github.com/codag-megalith/codag-cli/internal/fakeapi"
+	"github.com/codag-megalith/codag-cli/internal/project"
+	gomcp "github.com/mark3labs/mcp-go/mcp"
+)
+
+// gitRepo creates a git repo in a temp dir with the given origin remote.
+func gitRepo(t *testing.T, remote string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"remote", "add", "origin", remote},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	return dir
+}
+
+// isolateConfig keeps refreshed tokens out of the real ~/.codag.
+func isolateConfig(t *testing.T) {
+	t.Helper()
+	home := t.TempDir()
+	oldHome, oldEnvFile := config.CodagHome, config.EnvFile
+	config.CodagHome = home
+	config.EnvFile = filepath.Join(home, ".env")
+	t.Setenv("CODAG_ACCESS_TOKEN", "")
+	t.Setenv("CODAG_REFRESH_TOKEN", "")
+	t.Cleanup(func() {
+		config.CodagHome, config.EnvFile = oldHome, oldEnvFile
+	})
+}
+
+func callBrief(t *testing.T, client *Client, files ...interface{}) string {
+	t.Helper()
+	var req gomcp.CallToolRequest
+	req.Params.Name = "codag_brief"
+	req.Params.Arguments = map[string]interface{}{"files": files}
+
+	res, err := briefHandler(client)(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler error: %v", err)
+	}
+	if len(res.Content) == 0 {
+		t.Fatal("empty tool result")
+	}
+	text, ok := res.Content[0].(gomcp.TextContent)
+	if !ok {
+		t.Fatalf("expected text content, got %T", res.Content[0])
+	}
+	return text.Text
+}
+
+func TestBriefEndToEnd(t *testing.T) {
+	isolateConfig(t)
+	srv := fakeapi.New(t)
+	srv.AddRepo("octo", "widgets")
+	srv.Brief = json.RawMessage(`{"signals":[{"file":"main.go","severity":"danger"}]}`)
+
+	dir := gitRepo(t, "git@github.com:octo/widgets.git")
+	client := NewClient(srv.URL, srv.AccessToken, srv.RefreshToken, dir)
+	if !client.CheckAvailability() {
+		t.Fatal("expected repo to resolve")
+	}
+
+	out := callBrief(t, client, "main.go")
+	if !strings.Contains(out, `"severity": "danger"`) {
+		t.Fatalf("expected brief signals, got:\n%s", out)
+	}
+
+	var briefReq *fakeapi.Request
+	for _, r := range srv.Requests() {
+		if r.Path == "/api/brief" {
+			r := r
+			briefReq = &r
+		}
+	}
+	if briefReq == nil || !strings.Contains(briefReq.Body, `"repo":1`) {
+		t.Fatalf("expected brief request for repo 1, got %+v", briefReq)
+	}
+}
+
+func TestBriefUnregisteredRepo(t *testing.T) {
+	isolateConfig(t)
+	srv := fakeapi.New(t)
+
+	dir := gitRepo(t, "https://github.com/octo/unknown.git")
+	client := NewClient(srv.URL, srv.AccessToken, srv.RefreshToken, dir)
+	if client.CheckAvailability() {
+		t.Fatal("expected unregistered repo to be unavailable")
+	}
+
+	out := callBrief(t, client, "main.go")
+	if !strings.Contains(out, "codag_unavailable") {
+		t.Fatalf("expected unavailable response, got:\n%s", out)
+	}
+}
+
+func TestBriefSelfHostedRemote(t *testing.T) {
+	isolateConfig(t)
+	srv := fakeapi.New(t)
+	srv.AddRepoAt("gitlab", "git.acme.com", "platform/backend", "api")
+
+	dir := gitRepo(t, "git@git.acme.com:platform/backend/api.git")
+	client := NewClient(srv.URL, srv.AccessToken, srv.RefreshToken, dir)
+	if client.CheckAvailability() {
+		t.Fatal("expected an unconfigured host to be unavailable")
+	}
+
+	client.project = &project.Config{Hosts: map[string]string{"git.acme.com": "gitlab"}}
+	if !client.CheckAvailability() {
+		t.Fatal("expected repo on a configured host to resolve")
+	}
+}
+
+func TestAvailabilityPrefersRegisteredRemote(t *testing.T) {
+	isolateConfig(t)
+	srv := fakeapi.New(t)
+	srv.AddRepo("someone", "else")
+	srv.AddRepo("octo", "widgets")
+
+	dir := gitRepo(t, "git@github.com:me/widgets.git")
+	cmd := exec.Command("git", "remote", "add", "upstream", "https://github.com/octo/widgets.git")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git remote add: %v\n%s", err, out)
+	}
+
+	client := NewClient(srv.URL, srv.AccessToken, srv.RefreshToken, dir)
+	if !client.CheckAvailability() || client.repoID != 2 {
+		t.Fatalf("expected the upstream repo (2), got available=%v repo=%d", client.available, client.repoID)
+	}
+}
+
+func TestBriefRefreshesExpiredToken(t *testing.T) {
+	isolateConfig(t)
+	srv := fakeapi.New(t)
+	srv.AddRepo("octo", "widgets")
+
+	dir := gitRepo(t, "https://github.com/octo/widgets")
+	client := NewClient(srv.URL, srv.AccessToken, srv.RefreshToken, dir)
+	if !client.CheckAvailability() {
+		t.Fatal("expected repo to resolve")
+	}
+
+	srv.ExpireAccessToken()
+	out := callBrief(t, client, "main.go")
+	if !strings.Contains(out, "signals") {
+		t.Fatalf("expected brief after refresh, got:\n%s", out)
+	}
+	if srv.Refreshes() != 1 {
+		t.Fatalf("expected 1 refresh, got %d", srv.Refreshes())
+	}
+}
+
+func TestBriefRejectsEmptyFiles(t *testing.T) {
+	client := NewClient("http://127.0.0.1:0", "", "", t.TempDir())
+	var req gomcp.CallToolRequest
+	req.Params.Arguments = map[string]interface{}{"files": []interface{}{}}
+
+	res, _ := briefHandler(client)(context.Background(), req)
+	if !res.IsError {
+		t.Fatal("expected error result for empty files")
+	}
+}
+
+func TestBriefUpgradeRequired(t *testing.T) {
+	isolateConfig(t)
+	srv := fakeapi.New(t)
+	srv.AddRepo("octo", "widgets")
+	srv.MinVersion = "9.0.0"
+
+	dir := gitRepo(t, "https://github.com/octo/widgets")
+	client := NewClient(srv.URL, srv.AccessToken, srv.RefreshToken, dir)
+	if client.CheckAvailability() {
+		t.Fatal("expected unsupported client to be unavailable")
+	}
+
+	out := callBrief(t, client, "main.go")
+	if !strings.Contains(out, "codag_upgrade_required") || !strings.Contains(out, `"min_version": "9.0.0"`) {
+		t.Fatalf("expected structured upgrade error, got:\n%s", out)
+	}
+}
+
+func TestBriefHonoursProjectConfig(t *testing.T) {
+	isolateConfig(t)
+	srv := fakeapi.New(t)
+	srv.AddRepo("octo", "widgets")
+
+	dir := gitRepo(t, "git@github.com:octo/widgets.git")
+	client := NewClient(srv.URL, srv.AccessToken, srv.RefreshToken, dir)
+	client.project = &project.Config{Ignore: []string{"vendor/"}, Severity: project.Severity{Min: "warning"}}
+	if !client.CheckAvailability() {
+		t.Fatal("expected repo to resolve")
+	}
+
+	out := callBrief(t, client, "vendor/lib.go")
+	if !strings.Contains(out, "ignored by .codag.yml") {
+		t.Fatalf("expected ignored response, got:\n%s", out)
+	}
+	if srv.Called("POST", "/api/brief") {
+		t.Fatal("brief should not be requested when every file is ignored")
+	}
+
+	callBrief(t, client, "main.go", "vendor/lib.go")
+	var body string
+	for _, r := range srv.Requests() {
+		if r.Path == "/api/brief" {
+			body = r.Body
+		}
+	}
+	if strings.Contains(body, "vendor/lib.go") || !strings.Contains(body, `"min_severity":"warning"`) {
+		t.Fatalf("expected filtered files and min_severity, got %s", body)
+	}
+}
@@ -5,15 +5,17 @@ import (
 	"encoding/json"
 	"os"
 
+	"github.com/codag-megalith/codag-cli/internal/project"
 	gomcp "github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 )
 
-func Serve(workspacePath, serverURL, version string) error {
+func Serve(workspacePath, serverURL, version string, proj *project.Config) error {
 	token := os.Getenv("CODAG_ACCESS_TOKEN")
 	refreshToken := os.Getenv("CODAG_REFRESH_TOKEN")
 
 	client := NewClient(serverURL, token, refreshToken, workspacePath)
+	client.project = proj
 	client.CheckAvailability()
 
 	s := server.NewMCPServer(
@@ -17,12 +17,22 @@ type Result struct {
 
 // CodagEntry returns the MCP server configuration for Codag.
 func CodagEntry(serverURL string) map[string]interface{} {
+	return codagEntry(serverURL, "")
+}
+
+// codagEntry returns the MCP server configuration, pinned to a named
+// profile when profile is non-empty.
+func codagEntry(serverURL, profile string) map[string]interface{} {
+	env := map[string]string{
+		"CODAG_URL": serverURL,
+	}
+	if profile != "" {
+		env["CODAG_PROFILE"] = profile
+	}
 	return map[string]interface{}{
 		"command": "codag",
 		"args":    []string{"mcp", "serve", "."},
-		"env": map[string]string{
-			"CODAG_URL": serverURL,
-		},
+		"env":     env,
 	}
 }
 
@@ -31,17 +41,24 @@ func CodagEntry(serverURL string) map[string]interface{} {
 // Writes .vscode/mcp.json if .vscode/ exists.
 // Writes .codex/config.toml if .codex/ exists.
 func WriteAll(dir string, serverURL string) []Result {
+	return WriteAllForProfile(dir, serverURL, "")
+}
+
+// WriteAllForProfile is WriteAll with CODAG_PROFILE set in the server env,
+// so `codag mcp serve` uses that profile's tokens. An empty profile is
+// left out.
+func WriteAllForProfile(dir string, serverURL string, profile string) []Result {
 	var results []Result
 
 	// Always: .mcp.json at root (Claude Code + Cursor)
-	action, err := writeRootMCP(dir, serverURL)
+	action, err := writeRootMCP(dir, serverURL, profile)
 	if err == nil {
 		results = append(results, Result{Editor: "Claude Code / Cursor", Path: ".mcp.json", Action: action})
 	}
 
 	// If .vscode/ exists: .vscode/mcp.json
 	if dirExists(filepath.Join(dir, ".vscode")) {
-		action, err := writeVSCodeMCP(dir, serverURL)
+		action, err := writeVSCodeMCP(dir, serverURL, profile)
 		if err == nil {
 			results = append(results, Result{Editor: "VS Code", Path: ".vscode/mcp.json", Action: action})
 		}
@@ -49,7 +66,7 @@ func WriteAll(dir string, serverURL string) []Result {
 
 	// If .codex/ exists: .codex/config.toml
 	if dirExists(filepath.Join(dir, ".codex")) {
-		action, err := writeCodexTOML(dir, serverURL)
+		action, err := writeCodexTOML(dir, serverURL, profile)
 		if err == nil {
 			results = append(results, Result{Editor: "Codex", Path: ".codex/config.toml", Action: action})
 		}
@@ -58,26 +75,61 @@ func WriteAll(dir string, serverURL string) []Result {
 	return results
 }
 
+// WriteEditors writes MCP configs for the named editors only ("claude",
+// "cursor", "vscode", "codex"), creating .vscode/ or .codex/ as needed.
+// With no editors it behaves like WriteAllForProfile.
+func WriteEditors(dir string, serverURL string, profile string, editors []string) []Result {
+	if len(editors) == 0 {
+		return WriteAllForProfile(dir, serverURL, profile)
+	}
+	want := map[string]bool{}
+	for _, e := range editors {
+		want[e] = true
+	}
+
+	var results []Result
+	if want["claude"] || want["cursor"] {
+		label := "Claude Code / Cursor"
+		if !want["cursor"] {
+			label = "Claude Code"
+		} else if !want["claude"] {
+			label = "Cursor"
+		}
+		if action, err := writeRootMCP(dir, serverURL, profile); err == nil {
+			results = append(results, Result{Editor: label, Path: ".mcp.json", Action: action})
+		}
+	}
+	if want["vscode"] && os.MkdirAll(filepath.Join(dir, ".vscode"), 0755) == nil {
+		if action, err := writeVSCodeMCP(dir, serverURL, profile); err == nil {
+			results = append(results, Result{Editor: "VS Code", Path: ".vscode/mcp.json", Action: action})
+		}
+	}
+	if want["codex"] && os.MkdirAll(filepath.Join(dir, ".codex"), 0755) == nil {
+		if action, err := writeCodexTOML(dir, serverURL, profile); err == nil {
+			results = append(results, Result{Editor: "Codex", Path: ".codex/config.toml", Action: action})
+		}
+	}
+	return results
+}
+
 // Write creates or updates .mcp.json in the given directory (backward compat).
 // Returns ("created"|"updated"|"unchanged", error).
 func Write(dir string, serverURL string) (string, error) {
-	return writeRootMCP(dir, serverURL)
+	return writeRootMCP(dir, serverURL, "")
 }
 
 // writeRootMCP writes .mcp.json with mcpServers key (Claude Code + Cursor).
-func writeRootMCP(dir string, serverURL string) (string, error) {
-	return writeJSONConfig(filepath.Join(dir, ".mcp.json"), "mcpServers", serverURL)
+func writeRootMCP(dir string, serverURL string, profile string) (string, error) {
+	return writeJSONConfig(filepath.Join(dir, ".mcp.json"), "mcpServers", codagEntry(serverURL, profile))
 }
 
 // writeVSCodeMCP writes .vscode/mcp.json with servers key (VS Code Copilot).
-func writeVSCodeMCP(dir string, serverURL string) (string, error) {
-	return writeJSONConfig(filepath.Join(dir, ".vscode", "mcp.json"), "servers", serverURL)
+func writeVSCodeMCP(dir string, serverURL string, profile string) (string, error) {
+	return writeJSONConfig(filepath.Join(dir, ".vscode", "mcp.json"), "servers", codagEntry(serverURL, profile))
 }
 
 // writeJSONConfig writes a JSON MCP config file with the given wrapper key.
-func writeJSONConfig(path string, serversKey string, serverURL string) (string, error) {
-	entry := CodagEntry(serverURL)
-
+func writeJSONConfig(path string, serversKey string, entry map[string]interface{}) (string, error) {
 	var config map[string]interface{}
 	action := "created"
 
@@ -132,7 +184,7 @@ func writeJSONConfig(path string, serversKey string, serverURL string) (string,
 }
 
 // writeCodexTOML writes .codex/config.toml with [mcp_servers.codag] section.
-func writeCodexTOML(dir string, serverURL string) (string, error) {
+func writeCodexTOML(dir string, serverURL string, profile string) (string, error) {
 	path := filepath.Join(dir, ".codex", "config.toml")
 
 	// The TOML section we want
@@ -142,6 +194,9 @@ args = ["mcp", "serve", "."]
 
 [mcp_servers.codag.env]
 CODAG_URL = "%s"`, serverURL)
+	if profile != "" {
+		section += fmt.Sprintf("\nCODAG_PROFILE = \"%s\"", profile)
+	}
 
 	data, err := os.ReadFile(path)
 	if err != nil {
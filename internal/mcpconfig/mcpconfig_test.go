@@ -301,3 +301,40 @@ func TestVSCodeMergesExisting(t *testing.T) {
 		t.Fatal("codag was not added to .vscode/mcp.json")
 	}
 }
+
+func TestWriteAllForProfile(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, ".codex"), 0755)
+
+	WriteAllForProfile(dir, "https://codag.internal", "work")
+
+	data, _ := os.ReadFile(filepath.Join(dir, ".mcp.json"))
+	var config map[string]interface{}
+	json.Unmarshal(data, &config)
+	servers := config["mcpServers"].(map[string]interface{})
+	env := servers["codag"].(map[string]interface{})["env"].(map[string]interface{})
+	if env["CODAG_PROFILE"] != "work" {
+		t.Fatalf("expected CODAG_PROFILE=work, got %v", env["CODAG_PROFILE"])
+	}
+
+	toml, _ := os.ReadFile(filepath.Join(dir, ".codex", "config.toml"))
+	if !strings.Contains(string(toml), `CODAG_PROFILE = "work"`) {
+		t.Fatalf("expected CODAG_PROFILE in config.toml, got:\n%s", toml)
+	}
+}
+
+func TestWriteEditors(t *testing.T) {
+	dir := t.TempDir()
+
+	results := WriteEditors(dir, "https://api.codag.ai", "", []string{"codex"})
+
+	if len(results) != 1 || results[0].Path != ".codex/config.toml" {
+		t.Fatalf("expected only codex config, got %+v", results)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".mcp.json")); err == nil {
+		t.Fatal(".mcp.json should not be written when claude/cursor aren't listed")
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".codex", "config.toml")); err != nil {
+		t.Fatalf("expected .codex/config.toml to be created: %v", err)
+	}
+}
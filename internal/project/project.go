@@ -0,0 +1,271 @@
+// Package project reads .codag.yml, the per-repo settings a team checks
+// into its repo.
+//
+//	server: https://codag.internal   # API server for this repo
+//	ignore:                          # paths left out of briefs
+//	  - vendor/
+//	  - "*.pb.go"
+//	severity:
+//	  min: warning                   # lowest signal severity shown in briefs
+//	max_prs: 1000                    # default for init and index
+//	editors: [claude, vscode]        # MCP configs written by init
+//	remotes: [company]               # remotes tried after upstream and origin
+//	hosts:                           # self-hosted git servers
+//	  github.acme.com: github        # github, gitlab or bitbucket
+//
+// Values apply under flags and environment variables.
+package project
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/codag-megalith/codag-cli/internal/remote"
+	"gopkg.in/yaml.v3"
+)
+
+// FileNames are the names a project config may have, in lookup order.
+var FileNames = []string{".codag.yml", ".codag.yaml"}
+
+// Editors that can be listed under editors.
+var Editors = []string{"claude", "cursor", "vscode", "codex"}
+
+// Config is a parsed .codag.yml. The zero value means no project config.
+type Config struct {
+	Server   string            `yaml:"server"`
+	Ignore   []string          `yaml:"ignore"`
+	Severity Severity          `yaml:"severity"`
+	MaxPRs   int               `yaml:"max_prs"`
+	Editors  []string          `yaml:"editors"`
+	Remotes  []string          `yaml:"remotes"`
+	Hosts    map[string]string `yaml:"hosts"`
+
+	// Path is the file the config was read from, and Root its directory.
+	// RepoRoot is the git root above it, or Root outside a git repo.
+	Path     string `yaml:"-"`
+	Root     string `yaml:"-"`
+	RepoRoot string `yaml:"-"`
+}
+
+// Severity holds the severity thresholds for signals.
+type Severity struct {
+	Min string `yaml:"min"`
+}
+
+// Find looks for a project config in dir and its parents, stopping at the
+// git root. It returns "" if there is none.
+func Find(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		for _, name := range FileNames {
+			p := filepath.Join(dir, name)
+			if info, err := os.Stat(p); err == nil && !info.IsDir() {
+				return p, nil
+			}
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return "", nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// Load finds and parses the project config for dir. With no config file it
+// returns an empty Config.
+func Load(dir string) (*Config, error) {
+	p, err := Find(dir)
+	if err != nil || p == "" {
+		return &Config{}, err
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return &Config{}, err
+	}
+	cfg, err := Parse(data)
+	if err != nil {
+		return &Config{}, fmt.Errorf("%s: %w", p, err)
+	}
+	cfg.Path = p
+	cfg.Root = filepath.Dir(p)
+	cfg.RepoRoot = gitRoot(cfg.Root)
+	return cfg, nil
+}
+
+// gitRoot returns the nearest directory at or above dir that has a .git,
+// or dir itself if there is none.
+func gitRoot(dir string) string {
+	for d := dir; ; {
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			return d
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return dir
+		}
+		d = parent
+	}
+}
+
+// Parse parses and validates a project config. Unknown keys are errors so
+// typos don't go unnoticed.
+func Parse(data []byte) (*Config, error) {
+	cfg := &Config{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) validate() error {
+	if c.Server != "" && !strings.HasPrefix(c.Server, "http://") && !strings.HasPrefix(c.Server, "https://") {
+		return fmt.Errorf("server: %q is not an http(s) URL", c.Server)
+	}
+	if c.MaxPRs < 0 {
+		return fmt.Errorf("max_prs: must be positive")
+	}
+	for _, p := range c.Ignore {
+		if _, err := path.Match(strings.TrimSuffix(p, "/"), ""); err != nil {
+			return fmt.Errorf("ignore: bad pattern %q", p)
+		}
+	}
+	for _, r := range c.Remotes {
+		if strings.TrimSpace(r) == "" || strings.ContainsAny(r, " \t") {
+			return fmt.Errorf("remotes: %q is not a remote name", r)
+		}
+	}
+	for host, name := range c.Hosts {
+		if _, err := remote.ParseProvider(name); err != nil {
+			return fmt.Errorf("hosts: %s: %w", host, err)
+		}
+	}
+	for i, e := range c.Editors {
+		e = strings.ToLower(e)
+		if !isEditor(e) {
+			return fmt.Errorf("editors: unknown editor %q (known: %s)", e, strings.Join(Editors, ", "))
+		}
+		c.Editors[i] = e
+	}
+	return nil
+}
+
+func isEditor(name string) bool {
+	for _, e := range Editors {
+		if e == name {
+			return true
+		}
+	}
+	return false
+}
+
+// PreferredRemotes returns the remotes to look for the repo in, in order.
+func (c *Config) PreferredRemotes() []string {
+	if c == nil {
+		return remote.Preferred(nil)
+	}
+	return remote.Preferred(c.Remotes)
+}
+
+// GitHosts returns the hosts configured under hosts.
+func (c *Config) GitHosts() remote.Hosts {
+	hosts := remote.Hosts{}
+	if c == nil {
+		return hosts
+	}
+	for host, name := range c.Hosts {
+		p, _ := remote.ParseProvider(name)
+		hosts[strings.ToLower(host)] = p
+	}
+	return hosts
+}
+
+// Found reports whether a config file was loaded.
+func (c *Config) Found() bool {
+	return c != nil && c.Path != ""
+}
+
+// Ignored reports whether a file matches an ignore pattern. file is
+// relative to the repo root, or absolute. Patterns are relative to the
+// directory holding .codag.yml and only apply under it. They use
+// path.Match syntax; a pattern without a slash also matches base names,
+// and one ending in / matches everything under that directory.
+func (c *Config) Ignored(file string) bool {
+	if c == nil {
+		return false
+	}
+	if filepath.IsAbs(file) && c.RepoRoot != "" {
+		rel, err := filepath.Rel(c.RepoRoot, file)
+		if err != nil {
+			return false
+		}
+		file = rel
+	}
+	file = path.Clean(filepath.ToSlash(file))
+	if dir := c.configDir(); dir != "" {
+		rest, ok := strings.CutPrefix(file, dir+"/")
+		if !ok {
+			return false
+		}
+		file = rest
+	}
+	for _, p := range c.Ignore {
+		p = strings.TrimPrefix(filepath.ToSlash(p), "/")
+		if dir, ok := strings.CutSuffix(p, "/"); ok {
+			if file == dir || strings.HasPrefix(file, dir+"/") {
+				return true
+			}
+			continue
+		}
+		if ok, _ := path.Match(p, file); ok {
+			return true
+		}
+		if !strings.Contains(p, "/") {
+			if ok, _ := path.Match(p, path.Base(file)); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// configDir is Root relative to RepoRoot, with forward slashes, or "" when
+// the config sits at the repo root.
+func (c *Config) configDir() string {
+	if c.Root == "" || c.RepoRoot == "" {
+		return ""
+	}
+	rel, err := filepath.Rel(c.RepoRoot, c.Root)
+	if err != nil || rel == "." {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// FilterIgnored splits files into those to keep and those ignored.
+func (c *Config) FilterIgnored(files []string) (kept, ignored []string) {
+	for _, f := range files {
+		if c.Ignored(f) {
+			ignored = append(ignored, f)
+		} else {
+			kept = append(kept, f)
+		}
+	}
+	return kept, ignored
+}
@@ -0,0 +1,154 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadWalksUpToGitRoot(t *testing.T) {
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, ".git"), 0755)
+	sub := filepath.Join(root, "src", "pkg")
+	os.MkdirAll(sub, 0755)
+	os.WriteFile(filepath.Join(root, ".codag.yml"), []byte("server: https://codag.internal\nmax_prs: 200\n"), 0644)
+
+	cfg, err := Load(sub)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.Found() || cfg.Root != root {
+		t.Fatalf("expected config at %s, got %+v", root, cfg)
+	}
+	if cfg.Server != "https://codag.internal" || cfg.MaxPRs != 200 {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestFindStopsAtGitRoot(t *testing.T) {
+	outer := t.TempDir()
+	os.WriteFile(filepath.Join(outer, ".codag.yml"), []byte("max_prs: 5\n"), 0644)
+	repo := filepath.Join(outer, "repo")
+	os.MkdirAll(filepath.Join(repo, ".git"), 0755)
+
+	p, err := Find(repo)
+	if err != nil || p != "" {
+		t.Fatalf("config outside the git root should be ignored, got %q (%v)", p, err)
+	}
+
+	cfg, err := Load(repo)
+	if err != nil || cfg.Found() {
+		t.Fatalf("expected empty config, got %+v (%v)", cfg, err)
+	}
+}
+
+func TestParseAll(t *testing.T) {
+	cfg, err := Parse([]byte(`
+server: https://codag.internal
+ignore:
+  - vendor/
+  - "*.pb.go"
+  - docs/*.md
+severity:
+  min: warning
+max_prs: 1000
+editors: [Claude, vscode]
+remotes: [company]
+hosts:
+  Git.Acme.com: GitHub
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Severity.Min != "warning" || len(cfg.Ignore) != 3 {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+	if cfg.Editors[0] != "claude" || cfg.Editors[1] != "vscode" {
+		t.Fatalf("editors should be normalized, got %v", cfg.Editors)
+	}
+	if r := cfg.PreferredRemotes(); len(r) != 3 || r[2] != "company" {
+		t.Fatalf("unexpected remotes %v", r)
+	}
+	if cfg.GitHosts()["git.acme.com"] != "github" {
+		t.Fatalf("unexpected hosts %v", cfg.GitHosts())
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	if _, err := Parse(nil); err != nil {
+		t.Fatalf("empty file should be valid: %v", err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for input, want := range map[string]string{
+		"sever: https://x\n":     "field sever not found",
+		"server: codag.internal": "not an http(s) URL",
+		"max_prs: -1":            "max_prs",
+		"editors: [emacs]":       "unknown editor",
+		"ignore: ['[']":          "bad pattern",
+		"remotes: ['']":          "not a remote name",
+		"hosts: {a.com: gitea}":  "unknown provider",
+	} {
+		_, err := Parse([]byte(input))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("Parse(%q): expected error containing %q, got %v", input, want, err)
+		}
+	}
+}
+
+func TestIgnored(t *testing.T) {
+	cfg := &Config{Ignore: []string{"vendor/", "*.pb.go", "docs/*.md", "/gen/"}}
+	for file, want := range map[string]bool{
+		"vendor/github.com/x/y.go": true,
+		"vendor":                   true,
+		"api/service.pb.go":        true,
+		"docs/intro.md":            true,
+		"docs/guide/deep.md":       false,
+		"gen/types.go":             true,
+		"src/vendor.go":            false,
+		"main.go":                  false,
+		"./vendor/a.go":            true,
+	} {
+		if got := cfg.Ignored(file); got != want {
+			t.Fatalf("Ignored(%q) = %v, want %v", file, got, want)
+		}
+	}
+
+	var none *Config
+	if none.Ignored("main.go") {
+		t.Fatal("nil config should ignore nothing")
+	}
+}
+
+func TestIgnoredInSubdirectoryConfig(t *testing.T) {
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, ".git"), 0755)
+	svc := filepath.Join(root, "services", "api")
+	os.MkdirAll(svc, 0755)
+	os.WriteFile(filepath.Join(svc, ".codag.yml"), []byte("ignore:\n  - vendor/\n  - \"*.pb.go\"\n  - /gen/\n"), 0644)
+
+	cfg, err := Load(svc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.RepoRoot != root {
+		t.Fatalf("RepoRoot = %q, want %q", cfg.RepoRoot, root)
+	}
+	for file, want := range map[string]bool{
+		"services/api/vendor/x/y.go":                             true,
+		"services/api/proto/service.pb.go":                       true,
+		"services/api/gen/types.go":                              true,
+		"services/api/main.go":                                   false,
+		"vendor/x/y.go":                                          false,
+		"other/service.pb.go":                                    false,
+		"gen/types.go":                                           false,
+		filepath.Join(root, "services", "api", "vendor", "a.go"): true,
+		filepath.Join(root, "vendor", "a.go"):                    false,
+	} {
+		if got := cfg.Ignored(file); got != want {
+			t.Fatalf("Ignored(%q) = %v, want %v", file, got, want)
+		}
+	}
+}
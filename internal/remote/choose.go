@@ -0,0 +1,74 @@
+package remote
+
+import "strings"
+
+// DefaultRemotes are the remotes considered, in order, when no remote is
+// named. In a fork, upstream is the canonical repo and origin the fork.
+var DefaultRemotes = []string{"upstream", "origin"}
+
+// Remote is a git remote and the repo it points at.
+type Remote struct {
+	Name string
+	Repo Repo
+}
+
+// Preferred returns the remote names to consider: DefaultRemotes, then
+// extra (from project config), without duplicates.
+func Preferred(extra []string) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, n := range append(append([]string{}, DefaultRemotes...), extra...) {
+		if n != "" && !seen[n] {
+			seen[n] = true
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+// Candidates returns the named remotes of the checkout in dir that point
+// at a recognised repo, in the order given. Missing remotes are skipped.
+func Candidates(dir string, names []string, hosts Hosts) []Remote {
+	var remotes []Remote
+	for _, n := range names {
+		if repo, err := Detect(dir, n, hosts); err == nil {
+			remotes = append(remotes, Remote{Name: n, Repo: repo})
+		}
+	}
+	return remotes
+}
+
+// Choose picks the first candidate registered with Codag, or the first
+// candidate if none is. registered reports whether a repo is registered;
+// an error from it is returned unless a later candidate is registered.
+func Choose(candidates []Remote, registered func(Repo) (bool, error)) (Remote, bool, error) {
+	var firstErr error
+	for _, c := range candidates {
+		ok, err := registered(c.Repo)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if ok {
+			return c, true, nil
+		}
+	}
+	if len(candidates) == 0 {
+		return Remote{}, false, firstErr
+	}
+	return candidates[0], false, firstErr
+}
+
+// ForkOf returns a remote in candidates, other than chosen, that points
+// at a different repo with the same name, as origin and upstream do in a
+// fork.
+func ForkOf(chosen Remote, candidates []Remote) (Remote, bool) {
+	for _, c := range candidates {
+		if c.Name != chosen.Name && c.Repo != chosen.Repo && strings.EqualFold(c.Repo.Name, chosen.Repo.Name) {
+			return c, true
+		}
+	}
+	return Remote{}, false
+}
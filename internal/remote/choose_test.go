@@ -0,0 +1,69 @@
+package remote
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPreferred(t *testing.T) {
+	got := Preferred([]string{"company", "origin", ""})
+	want := []string{"upstream", "origin", "company"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestCandidates(t *testing.T) {
+	sshConfig(t, nil)
+	dir := gitRepo(t,
+		[2]string{"remote.origin.url", "git@github.com:me/widgets.git"},
+		[2]string{"remote.upstream.url", "https://github.com/octo/widgets"},
+		[2]string{"remote.heroku.url", "https://git.heroku.com/widgets.git"},
+	)
+	got := Candidates(dir, []string{"upstream", "origin", "heroku", "company"}, nil)
+	if len(got) != 2 || got[0].Name != "upstream" || got[1].Name != "origin" || got[1].Repo.Owner != "me" {
+		t.Fatalf("got %+v", got)
+	}
+}
+
+func TestChoose(t *testing.T) {
+	upstream := Remote{"upstream", Repo{GitHub, "github.com", "octo", "widgets"}}
+	origin := Remote{"origin", Repo{GitHub, "github.com", "me", "widgets"}}
+	candidates := []Remote{upstream, origin}
+
+	only := func(r Remote) func(Repo) (bool, error) {
+		return func(repo Repo) (bool, error) { return repo == r.Repo, nil }
+	}
+	if got, ok, err := Choose(candidates, only(origin)); got != origin || !ok || err != nil {
+		t.Fatalf("expected the registered origin, got %+v %v %v", got, ok, err)
+	}
+	if got, ok, err := Choose(candidates, only(Remote{})); got != upstream || ok || err != nil {
+		t.Fatalf("expected upstream when nothing is registered, got %+v %v %v", got, ok, err)
+	}
+
+	boom := errors.New("boom")
+	flaky := func(repo Repo) (bool, error) {
+		if repo == upstream.Repo {
+			return false, boom
+		}
+		return true, nil
+	}
+	if got, ok, err := Choose(candidates, flaky); got != origin || !ok || err != nil {
+		t.Fatalf("expected a later registered remote to win over an error, got %+v %v %v", got, ok, err)
+	}
+	if _, _, err := Choose([]Remote{upstream}, flaky); err != boom {
+		t.Fatalf("expected the error, got %v", err)
+	}
+
+	if fork, ok := ForkOf(upstream, candidates); !ok || fork != origin {
+		t.Fatalf("expected origin as the fork, got %+v", fork)
+	}
+	if _, ok := ForkOf(upstream, []Remote{upstream, {"other", Repo{GitHub, "github.com", "octo", "gadgets"}}}); ok {
+		t.Fatal("a different repo is not a fork")
+	}
+}
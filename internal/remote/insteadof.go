@@ -0,0 +1,61 @@
+package remote
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// insteadOfRule is a url.<base>.insteadOf setting: URLs starting with
+// prefix are rewritten to start with base.
+type insteadOfRule struct {
+	prefix, base string
+}
+
+// insteadOfRules reads the insteadOf rewrites that apply in dir, from the
+// repo's config and the user's and system's.
+func insteadOfRules(dir string) []insteadOfRule {
+	cmd := exec.Command("git", "config", "--get-regexp", `^url\..*\.insteadof$`)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil // none set, or not a git repo
+	}
+	return parseInsteadOf(string(out))
+}
+
+// parseInsteadOf parses `git config --get-regexp` output, lines of
+// "url.<base>.insteadof <prefix>".
+func parseInsteadOf(out string) []insteadOfRule {
+	var rules []insteadOfRule
+	for _, line := range strings.Split(out, "\n") {
+		key, prefix, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		base, ok := strings.CutPrefix(key, "url.")
+		if !ok {
+			continue
+		}
+		base, ok = strings.CutSuffix(base, ".insteadof")
+		if !ok {
+			continue
+		}
+		rules = append(rules, insteadOfRule{prefix: prefix, base: base})
+	}
+	return rules
+}
+
+// rewriteURL applies the rule with the longest matching prefix, as git
+// does.
+func rewriteURL(raw string, rules []insteadOfRule) string {
+	best := -1
+	for i, r := range rules {
+		if strings.HasPrefix(raw, r.prefix) && (best < 0 || len(r.prefix) > len(rules[best].prefix)) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return raw
+	}
+	return rules[best].base + strings.TrimPrefix(raw, rules[best].prefix)
+}
@@ -0,0 +1,307 @@
+// Package remote identifies the hosted repo behind a git remote: which
+// provider serves it, on which host, and its path there.
+//
+// github.com, gitlab.com and bitbucket.org are known. Self-hosted
+// instances such as GitHub Enterprise Server are recognised by name
+// ("github.acme.com", "gitlab.internal") or configured explicitly with
+// Hosts.
+package remote
+
+import (
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strings"
+)
+
+// Provider is a git hosting service.
+type Provider string
+
+const (
+	GitHub    Provider = "github"
+	GitLab    Provider = "gitlab"
+	Bitbucket Provider = "bitbucket"
+)
+
+// Providers lists the supported providers.
+var Providers = []Provider{GitHub, GitLab, Bitbucket}
+
+// DisplayName returns the provider's name as its users know it.
+func (p Provider) DisplayName() string {
+	switch p {
+	case GitHub:
+		return "GitHub"
+	case GitLab:
+		return "GitLab"
+	case Bitbucket:
+		return "Bitbucket"
+	}
+	return string(p)
+}
+
+// ParseProvider checks a provider name, case-insensitively.
+func ParseProvider(name string) (Provider, error) {
+	p := Provider(strings.ToLower(strings.TrimSpace(name)))
+	for _, known := range Providers {
+		if p == known {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("unknown provider %q (known: github, gitlab, bitbucket)", name)
+}
+
+// Hosts maps hostnames to the provider serving them.
+type Hosts map[string]Provider
+
+// publicHosts are the providers' hosted services.
+var publicHosts = Hosts{
+	"github.com":    GitHub,
+	"gitlab.com":    GitLab,
+	"bitbucket.org": Bitbucket,
+}
+
+// ParseHosts parses a comma-separated list of host=provider pairs, as in
+// "github.acme.com=github,git.acme.com=gitlab".
+func ParseHosts(s string) (Hosts, error) {
+	hosts := Hosts{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		host, name, ok := strings.Cut(pair, "=")
+		host = strings.ToLower(strings.TrimSpace(host))
+		if !ok || host == "" {
+			return nil, fmt.Errorf("%q is not host=provider", pair)
+		}
+		p, err := ParseProvider(name)
+		if err != nil {
+			return nil, err
+		}
+		hosts[host] = p
+	}
+	return hosts, nil
+}
+
+// Merge returns h with other's entries added, other winning on conflict.
+func (h Hosts) Merge(other Hosts) Hosts {
+	merged := Hosts{}
+	for host, p := range h {
+		merged[host] = p
+	}
+	for host, p := range other {
+		merged[strings.ToLower(host)] = p
+	}
+	return merged
+}
+
+// Provider returns the provider serving host: a configured host first,
+// then the public services, then a guess from the hostname.
+func (h Hosts) Provider(host string) (Provider, bool) {
+	host = strings.ToLower(host)
+	if p, ok := h[host]; ok {
+		return p, true
+	}
+	if p, ok := publicHosts[host]; ok {
+		return p, true
+	}
+	for _, label := range strings.Split(host, ".") {
+		switch label {
+		case "github", "ghes":
+			return GitHub, true
+		case "gitlab":
+			return GitLab, true
+		case "bitbucket":
+			return Bitbucket, true
+		}
+	}
+	return "", false
+}
+
+// Repo is a repo on a git host.
+type Repo struct {
+	Provider Provider
+	Host     string // lowercased, without a port
+	Owner    string // user or organisation; GitLab groups may be nested ("group/sub")
+	Name     string
+}
+
+// Path is the repo's path on its host, e.g. "octo/widgets".
+func (r Repo) Path() string {
+	return r.Owner + "/" + r.Name
+}
+
+// URL is the repo's canonical web URL.
+func (r Repo) URL() string {
+	return "https://" + r.Host + "/" + r.Path()
+}
+
+func (r Repo) String() string {
+	return r.URL()
+}
+
+// UnknownHostError is returned for remotes on a host that isn't known or
+// configured.
+type UnknownHostError struct {
+	Host string
+}
+
+func (e *UnknownHostError) Error() string {
+	return fmt.Sprintf("unrecognised git host %q", e.Host)
+}
+
+// Parse identifies the repo a remote URL points at. It accepts HTTPS URLs,
+// ssh:// and git+ssh:// URLs (with or without a port) and scp-style
+// "git@host:owner/repo.git" addresses. Credentials in the URL are dropped.
+// Web URLs pointing inside a repo, such as ".../owner/repo/pull/3", are
+// trimmed to the repo.
+//
+// Parse doesn't look at git or SSH configuration; Resolve and Detect do.
+func Parse(raw string, hosts Hosts) (Repo, error) {
+	return parse(raw, hosts, nil)
+}
+
+// parse is Parse with SSH host aliases resolved by alias.
+func parse(raw string, hosts Hosts, alias func(string) string) (Repo, error) {
+	raw = strings.TrimSpace(raw)
+	host, repoPath, viaSSH, err := split(raw)
+	if err != nil {
+		return Repo{}, err
+	}
+	if viaSSH && alias != nil {
+		host = strings.ToLower(alias(host))
+	}
+	provider, ok := hosts.Provider(host)
+	if !ok {
+		return Repo{}, &UnknownHostError{Host: host}
+	}
+
+	segments := pathSegments(repoPath)
+	switch provider {
+	case GitLab:
+		// GitLab ends the project path at "/-/" in web URLs
+		for i, s := range segments {
+			if s == "-" {
+				segments = segments[:i]
+				break
+			}
+		}
+	default:
+		if len(segments) > 2 {
+			segments = segments[:2]
+		}
+	}
+	if len(segments) < 2 {
+		return Repo{}, fmt.Errorf("%q does not name a repo", Redact(raw))
+	}
+	last := len(segments) - 1
+	return Repo{
+		Provider: provider,
+		Host:     host,
+		Owner:    strings.Join(segments[:last], "/"),
+		Name:     segments[last],
+	}, nil
+}
+
+// split returns the host and path of a remote URL, and whether it is
+// reached over SSH, where the host may be an alias.
+func split(raw string) (host, repoPath string, viaSSH bool, err error) {
+	if raw == "" {
+		return "", "", false, fmt.Errorf("empty remote URL")
+	}
+	scheme, rest, hasScheme := strings.Cut(raw, "://")
+	if !hasScheme {
+		host, repoPath, err = splitSCP(raw)
+		return host, repoPath, true, err
+	}
+
+	scheme = strings.ToLower(scheme)
+	switch scheme {
+	case "https", "http", "git":
+	case "ssh", "git+ssh", "ssh+git":
+		viaSSH = true
+	default:
+		return "", "", false, fmt.Errorf("unsupported remote scheme %q", scheme)
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		// A common slip: an scp-style path in an ssh:// URL, as in
+		// ssh://git@github.com:octo/widgets.git
+		if viaSSH {
+			host, repoPath, err = splitSCP(rest)
+			return host, repoPath, true, err
+		}
+		return "", "", false, fmt.Errorf("%q is not a remote URL", Redact(raw))
+	}
+	if u.Hostname() == "" {
+		return "", "", false, fmt.Errorf("%q has no host", Redact(raw))
+	}
+	return strings.ToLower(u.Hostname()), u.Path, viaSSH, nil
+}
+
+// splitSCP splits an scp-style address, [user@]host:path.
+func splitSCP(raw string) (host, repoPath string, err error) {
+	hostPart, p, ok := strings.Cut(raw, ":")
+	if !ok || hostPart == "" || strings.Contains(hostPart, "/") {
+		return "", "", fmt.Errorf("%q is not a remote URL", Redact(raw))
+	}
+	if i := strings.LastIndex(hostPart, "@"); i >= 0 {
+		hostPart = hostPart[i+1:]
+	}
+	return strings.ToLower(hostPart), p, nil
+}
+
+// Redact removes credentials from a remote URL so it can be shown or
+// logged.
+func Redact(raw string) string {
+	scheme, rest, ok := strings.Cut(raw, "://")
+	if !ok {
+		return raw
+	}
+	authority, p, _ := strings.Cut(rest, "/")
+	if i := strings.LastIndex(authority, "@"); i >= 0 {
+		user, _, hasPassword := strings.Cut(authority[:i], ":")
+		if hasPassword || scheme == "https" || scheme == "http" {
+			// A user alone in an HTTPS URL is often a token
+			user = "REDACTED"
+		}
+		authority = user + authority[i:]
+	}
+	if p != "" || strings.HasSuffix(rest, "/") {
+		return scheme + "://" + authority + "/" + p
+	}
+	return scheme + "://" + authority
+}
+
+// pathSegments splits a repo path, dropping empty segments and a .git
+// suffix.
+func pathSegments(p string) []string {
+	p = strings.TrimSuffix(strings.Trim(p, "/"), ".git")
+	var segments []string
+	for _, s := range strings.Split(p, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
+
+// Resolve identifies the repo a remote URL given on the command line
+// points at, applying the url.<base>.insteadOf rewrites from the git
+// config of dir and SSH host aliases, as git would when fetching.
+func Resolve(dir, raw string, hosts Hosts) (Repo, error) {
+	return parse(rewriteURL(raw, insteadOfRules(dir)), hosts, sshHostName)
+}
+
+// Detect identifies the repo behind a remote of the git checkout in dir.
+func Detect(dir, name string, hosts Hosts) (Repo, error) {
+	// get-url applies insteadOf rewrites itself
+	cmd := exec.Command("git", "remote", "get-url", name)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return Repo{}, fmt.Errorf("no %s remote", name)
+	}
+	return parse(strings.TrimSpace(string(out)), hosts, sshHostName)
+}
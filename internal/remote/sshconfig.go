@@ -0,0 +1,131 @@
+package remote
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// SSHConfigPath is the SSH client config read for host aliases. A
+// variable so tests can point it elsewhere.
+var SSHConfigPath = defaultSSHConfigPath()
+
+func defaultSSHConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".ssh", "config")
+}
+
+// sshHostName returns the HostName ~/.ssh/config gives an alias such as
+// "github-work", or the alias itself if there is none.
+func sshHostName(alias string) string {
+	if SSHConfigPath == "" {
+		return alias
+	}
+	if name, ok := lookupHostName(SSHConfigPath, alias, 0); ok {
+		return strings.ReplaceAll(name, "%h", alias)
+	}
+	return alias
+}
+
+// maxIncludeDepth matches OpenSSH's limit on nested Include directives.
+const maxIncludeDepth = 16
+
+// lookupHostName finds the first HostName that applies to alias in an SSH
+// config file. As in ssh, the first value found wins. Match blocks aren't
+// evaluated and are skipped.
+func lookupHostName(file, alias string, depth int) (string, bool) {
+	if depth > maxIncludeDepth {
+		return "", false
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	active := true // settings before the first Host apply to every host
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		keyword, args := splitSSHLine(scanner.Text())
+		switch keyword {
+		case "host":
+			active = hostMatches(args, alias)
+		case "match":
+			active = false
+		case "include":
+			if !active {
+				continue
+			}
+			for _, pattern := range args {
+				for _, inc := range includeFiles(pattern) {
+					if name, ok := lookupHostName(inc, alias, depth+1); ok {
+						return name, true
+					}
+				}
+			}
+		case "hostname":
+			if active && len(args) > 0 {
+				return args[0], true
+			}
+		}
+	}
+	return "", false
+}
+
+// splitSSHLine splits a config line into its lowercased keyword and
+// arguments. Keywords may be separated from arguments by "=".
+func splitSSHLine(line string) (string, []string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", nil
+	}
+	keyword, rest := line, ""
+	if i := strings.IndexAny(line, " \t="); i >= 0 {
+		keyword, rest = line[:i], strings.TrimLeft(line[i:], " \t")
+		rest = strings.TrimPrefix(rest, "=")
+	}
+	var args []string
+	for _, a := range strings.Fields(rest) {
+		args = append(args, strings.Trim(a, `"`))
+	}
+	return strings.ToLower(keyword), args
+}
+
+// hostMatches applies a Host line's patterns: any positive match, and no
+// negated one.
+func hostMatches(patterns []string, alias string) bool {
+	matched := false
+	for _, p := range patterns {
+		if neg, ok := strings.CutPrefix(p, "!"); ok {
+			if m, _ := path.Match(neg, alias); m {
+				return false
+			}
+			continue
+		}
+		if m, _ := path.Match(p, alias); m {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// includeFiles expands an Include argument. Relative paths are relative
+// to ~/.ssh.
+func includeFiles(pattern string) []string {
+	if rest, ok := strings.CutPrefix(pattern, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil
+		}
+		pattern = filepath.Join(home, rest)
+	} else if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(filepath.Dir(SSHConfigPath), pattern)
+	}
+	files, _ := filepath.Glob(pattern)
+	return files
+}
@@ -2,11 +2,20 @@ package ui
 
 import (
 	"fmt"
+	"io"
 	"os"
 )
 
+// out receives messages and spinners. Errors always go to stderr.
+var out io.Writer = os.Stdout
+
+// SetOutput sends messages and spinners to w for the rest of the run.
+func SetOutput(w io.Writer) {
+	out = w
+}
+
 func Success(msg string) {
-	fmt.Println(Green.Render("✓") + " " + msg)
+	fmt.Fprintln(out, Green.Render("✓")+" "+msg)
 }
 
 func Error(msg string) {
@@ -14,21 +23,21 @@ func Error(msg string) {
 }
 
 func Warn(msg string) {
-	fmt.Println(Yellow.Render("!") + " " + msg)
+	fmt.Fprintln(out, Yellow.Render("!")+" "+msg)
 }
 
 func Info(msg string) {
-	fmt.Println(Cyan.Render("›") + " " + msg)
+	fmt.Fprintln(out, Cyan.Render("›")+" "+msg)
 }
 
 func Keyval(key, value string) {
-	fmt.Printf("  %s  %s\n", Dim.Render(key+":"), value)
+	fmt.Fprintf(out, "  %s  %s\n", Dim.Render(key+":"), value)
 }
 
 func Blank() {
-	fmt.Println()
+	fmt.Fprintln(out)
 }
 
 func CodeBlock(content string) {
-	fmt.Println(CodeBlockStyle.Render(content))
+	fmt.Fprintln(out, CodeBlockStyle.Render(content))
 }
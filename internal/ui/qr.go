@@ -0,0 +1,22 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// QRCode renders content as a QR code for the terminal, two modules per
+// character cell. Dark modules are drawn as blanks so the code scans on
+// the usual light-on-dark terminal.
+func QRCode(content string) (string, error) {
+	q, err := qrcode.New(content, qrcode.Low)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(strings.TrimRight(q.ToSmallString(false), "\n"), "\n")
+	for i, l := range lines {
+		lines[i] = "  " + l
+	}
+	return strings.Join(lines, "\n"), nil
+}
@@ -10,6 +10,15 @@ import (
 var brailleFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
 var asciiFrames = []string{"|", "/", "-", "\\"}
 
+// spinnersEnabled is off when output is for machines, where animation
+// would only add noise.
+var spinnersEnabled = true
+
+// SetSpinners turns spinners on or off for the rest of the run.
+func SetSpinners(enabled bool) {
+	spinnersEnabled = enabled
+}
+
 type Spinner struct {
 	mu      sync.Mutex
 	message string
@@ -30,20 +39,24 @@ func NewSpinner(message string) *Spinner {
 }
 
 func (s *Spinner) Start() {
+	if !spinnersEnabled {
+		return
+	}
+	w := out
 	go func() {
 		i := 0
 		for {
 			select {
 			case <-s.done:
 				// Clear the spinner line
-				fmt.Print("\r\033[2K")
+				fmt.Fprint(w, "\r\033[2K")
 				return
 			default:
 				s.mu.Lock()
 				msg := s.message
 				s.mu.Unlock()
 				frame := Cyan.Render(s.frames[i%len(s.frames)])
-				fmt.Printf("\r\033[2K%s %s", frame, msg)
+				fmt.Fprintf(w, "\r\033[2K%s %s", frame, msg)
 				i++
 				time.Sleep(80 * time.Millisecond)
 			}
@@ -63,6 +76,9 @@ func (s *Spinner) Stop() {
 		// Already stopped
 	default:
 		close(s.done)
+		if !spinnersEnabled {
+			return
+		}
 		// Give the goroutine a moment to clear the line
 		time.Sleep(100 * time.Millisecond)
 	}
@@ -0,0 +1,46 @@
+// Package version compares CLI version strings.
+package version
+
+import (
+	"strconv"
+	"strings"
+)
+
+// IsNewer returns true if a > b using semantic versioning comparison.
+// A leading "v" is ignored on both sides.
+func IsNewer(a, b string) bool {
+	aParts := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bParts := strings.Split(strings.TrimPrefix(b, "v"), ".")
+
+	// Pad to same length
+	maxLen := len(aParts)
+	if len(bParts) > maxLen {
+		maxLen = len(bParts)
+	}
+	for len(aParts) < maxLen {
+		aParts = append(aParts, "0")
+	}
+	for len(bParts) < maxLen {
+		bParts = append(bParts, "0")
+	}
+
+	// Compare each part
+	for i := 0; i < maxLen; i++ {
+		aNum, _ := strconv.Atoi(aParts[i])
+		bNum, _ := strconv.Atoi(bParts[i])
+		if aNum > bNum {
+			return true
+		}
+		if aNum < bNum {
+			return false
+		}
+	}
+	return false
+}
+
+// IsRelease reports whether v looks like a released version rather than a
+// dev build, i.e. it starts with a digit after an optional "v".
+func IsRelease(v string) bool {
+	v = strings.TrimPrefix(v, "v")
+	return v != "" && v[0] >= '0' && v[0] <= '9'
+}